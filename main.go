@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/caarlos0/env/v11"
@@ -18,7 +19,6 @@ import (
 	"github.com/charmbracelet/glamour/styles"
 	"github.com/charmbracelet/glow/v2/ui"
 	"github.com/charmbracelet/glow/v2/utils"
-	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	gap "github.com/muesli/go-app-paths"
 	"github.com/spf13/cobra"
@@ -151,7 +151,7 @@ func sourceFromArg(arg string) (*source, error) {
 // validateStyle checks if the style is a default style, if not, checks that
 // the custom style exists.
 func validateStyle(style string) error {
-	if style != "auto" && styles.DefaultStyles[style] == nil {
+	if !slices.Contains(utils.BuiltinStyles(), style) {
 		style = utils.ExpandPath(style)
 		if _, err := os.Stat(style); errors.Is(err, fs.ErrNotExist) {
 			return fmt.Errorf("specified style does not exist: %s", style)
@@ -192,10 +192,7 @@ func validateOptions(cmd *cobra.Command) error {
 	// Detect terminal width
 	if !cmd.Flags().Changed("width") { //nolint:nestif
 		if isTerminal && width == 0 {
-			w, _, err := term.GetSize(int(os.Stdout.Fd()))
-			if err == nil {
-				width = uint(w) //nolint:gosec
-			}
+			width = uint(utils.TerminalWidth()) //nolint:gosec
 
 			if width > 120 {
 				width = 120
@@ -275,6 +272,10 @@ func executeCLI(cmd *cobra.Command, src *source, w io.Writer) error {
 	if err != nil {
 		return fmt.Errorf("unable to read from reader: %w", err)
 	}
+	b, err = utils.ToUTF8(b)
+	if err != nil {
+		return err
+	}
 
 	cwd, err := os.Getwd()
 	if err != nil {
@@ -282,6 +283,7 @@ func executeCLI(cmd *cobra.Command, src *source, w io.Writer) error {
 	}
 
 	processedPaths := make(map[string]bool)
+	var opts utils.PreprocessOptions
 	// src.URL will be empty when reading from stdin.
 	if src.URL != "" {
 		initialFilePath, err := filepath.Abs(src.URL)
@@ -292,9 +294,16 @@ func executeCLI(cmd *cobra.Command, src *source, w io.Writer) error {
 
 		// Use the directory of the initial file as the current directory
 		cwd = filepath.Dir(initialFilePath)
+
+		if !isURL(src.URL) {
+			opts.Path = initialFilePath
+		}
 	}
 
-	b = utils.PreprocessDynamicText(b, cwd, processedPaths)
+	if _, _, err := utils.ExtractFrontmatterVarsE(b); err != nil {
+		log.Warn("Could not parse frontmatter", "err", err)
+	}
+	b = utils.PreprocessDynamicTextWithOptions(b, cwd, processedPaths, opts)
 
 	// render
 	var baseURL string
@@ -306,16 +315,17 @@ func executeCLI(cmd *cobra.Command, src *source, w io.Writer) error {
 
 	isCode := !utils.IsMarkdownFile(src.URL)
 
-	// initialize glamour
-	r, err := glamour.NewTermRenderer(
-		glamour.WithColorProfile(lipgloss.ColorProfile()),
-		utils.GlamourStyle(style, isCode),
-		glamour.WithWordWrap(int(width)), //nolint:gosec
+	// initialize glamour; width is only 0 here when -w 0 disabled wrapping
+	rendererWidth := int(width) //nolint:gosec
+	if rendererWidth == 0 {
+		rendererWidth = -1
+	}
+	r, err := utils.NewRenderer(style, rendererWidth, isCode,
 		glamour.WithBaseURL(baseURL),
 		glamour.WithPreservedNewLines(),
 	)
 	if err != nil {
-		return fmt.Errorf("unable to create renderer: %w", err)
+		return err
 	}
 
 	content := string(b)
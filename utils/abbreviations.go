@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// abbreviationPattern matches a Markdown Extra abbreviation definition such
+// as "*[HTML]: HyperText Markup Language".
+var abbreviationPattern = regexp.MustCompile(`(?m)^ {0,3}\*\[([^\]]+)\]:[ \t]*(.*?)[ \t]*(?:\r?\n|$)`)
+
+// ExpandAbbreviations removes the Markdown Extra abbreviation definitions
+// from content and spells out each abbreviation in parentheses after its
+// first use, as in "HTML (HyperText Markup Language)". Abbreviations match
+// case-sensitively and only as whole words. The frontmatter, code, URLs and
+// HTML are left alone.
+func ExpandAbbreviations(content []byte) []byte {
+	body := RemoveFrontmatter(content)
+	frontmatter := content[:len(content)-len(body)]
+
+	defs := make(map[string]string)
+	body = mapOutsideFences(body, func(b []byte) []byte {
+		return abbreviationPattern.ReplaceAllFunc(b, func(match []byte) []byte {
+			m := abbreviationPattern.FindSubmatch(match)
+			if abbr := strings.TrimSpace(string(m[1])); abbr != "" && len(m[2]) > 0 {
+				defs[abbr] = string(m[2])
+			}
+			return nil
+		})
+	})
+	if len(defs) == 0 {
+		return content
+	}
+
+	abbrs := make([]string, 0, len(defs))
+	for abbr := range defs {
+		abbrs = append(abbrs, regexp.QuoteMeta(abbr))
+	}
+	// longest first, so "HTML5" wins over "HTML"
+	sort.Slice(abbrs, func(i, j int) bool { return len(abbrs[i]) > len(abbrs[j]) })
+	usePattern := regexp.MustCompile(strings.Join(abbrs, "|"))
+
+	body = mapOutsideFences(body, func(b []byte) []byte {
+		var out bytes.Buffer
+		last := 0
+		for _, loc := range typographyProtectedPattern.FindAllIndex(b, -1) {
+			out.Write(expandAbbreviationUses(b[last:loc[0]], usePattern, defs))
+			out.Write(b[loc[0]:loc[1]])
+			last = loc[1]
+		}
+		out.Write(expandAbbreviationUses(b[last:], usePattern, defs))
+		return out.Bytes()
+	})
+
+	return append(append([]byte{}, frontmatter...), body...)
+}
+
+// expandAbbreviationUses appends the definition after the first whole-word
+// use in b of every abbreviation left in defs, and removes the ones it
+// expands from defs.
+func expandAbbreviationUses(b []byte, usePattern *regexp.Regexp, defs map[string]string) []byte {
+	isWord := func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+	var out bytes.Buffer
+	last := 0
+	for _, loc := range usePattern.FindAllIndex(b, -1) {
+		before, _ := utf8.DecodeLastRune(b[:loc[0]])
+		after, _ := utf8.DecodeRune(b[loc[1]:])
+		abbr := string(b[loc[0]:loc[1]])
+		def, ok := defs[abbr]
+		if !ok || isWord(before) || isWord(after) {
+			continue
+		}
+		out.Write(b[last:loc[1]])
+		out.WriteString(" (" + def + ")")
+		last = loc[1]
+		delete(defs, abbr)
+	}
+	out.Write(b[last:])
+	return out.Bytes()
+}
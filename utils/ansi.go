@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// ansiPattern matches CSI sequences such as SGR colors and cursor movement,
+// OSC sequences such as hyperlinks and window titles, and other two-byte
+// escape sequences.
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[0-~]`)
+
+// StripANSI removes terminal escape sequences from rendered output, leaving
+// just the text.
+func StripANSI(b []byte) []byte {
+	return ansiPattern.ReplaceAll(b, nil)
+}
+
+// VisibleWidth returns the number of terminal columns s takes up: wide
+// runes such as CJK count as two, zero-width and combining runes as none,
+// and escape sequences are ignored.
+func VisibleWidth(s string) int {
+	return runewidth.StringWidth(string(StripANSI([]byte(s))))
+}
+
+// trailingANSIPattern matches the escape sequences at the end of a line.
+var trailingANSIPattern = regexp.MustCompile(`(?:` + ansiPattern.String() + `)+\r?$|\r$`)
+
+// PadToWidth pads every line of rendered output with spaces so it takes up
+// width columns, ignoring escape sequences when measuring. The spaces go
+// before any escape sequences ending the line, so a background color that
+// is reset at the end of the line fills the padding too. Lines that are
+// already as wide or wider are left alone.
+func PadToWidth(b []byte, width int) []byte {
+	lines := bytes.Split(b, []byte("\n"))
+	for i, line := range lines {
+		if i == len(lines)-1 && len(line) == 0 {
+			break // nothing follows the final line break
+		}
+		n := width - VisibleWidth(string(line))
+		if n <= 0 {
+			continue
+		}
+		at := len(line)
+		if loc := trailingANSIPattern.FindIndex(line); loc != nil {
+			at = loc[0]
+		}
+		padded := append(append([]byte{}, line[:at]...), bytes.Repeat([]byte(" "), n)...)
+		lines[i] = append(padded, line[at:]...)
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
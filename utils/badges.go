@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Modes understood by SimplifyBadgesWithMode.
+const (
+	// BadgesText collapses badges into a compact list of text links.
+	BadgesText = "text"
+	// BadgesRemove removes badges entirely.
+	BadgesRemove = "remove"
+)
+
+var (
+	badgePattern    = regexp.MustCompile(`\[!\[([^\]]*)\]\([^)]*\)\]\(\s*<?([^)\s>]*)>?[^)]*\)`)
+	badgeRunPattern = regexp.MustCompile(badgePattern.String() + `(?:[ \t]*\r?\n?[ \t]*` + badgePattern.String() + `)*`)
+	// badgeLinePattern matches lines left empty by removed badges.
+	badgeLinePattern = regexp.MustCompile("(?m)^[ \t]*\x00[ \t]*(?:\r?\n|$)")
+)
+
+// SimplifyBadges collapses runs of badges, images wrapped in links like the
+// ones shields.io generates, into a single line of text links separated by
+// middle dots. Badges in code are left alone.
+func SimplifyBadges(content []byte) []byte {
+	return SimplifyBadgesWithMode(content, BadgesText)
+}
+
+// SimplifyBadgesWithMode is like SimplifyBadges but lets the caller pick
+// between BadgesText and BadgesRemove. Unknown modes leave badges as-is.
+func SimplifyBadgesWithMode(content []byte, mode string) []byte {
+	if mode != BadgesText && mode != BadgesRemove {
+		return content
+	}
+
+	return mapOutsideFences(content, func(b []byte) []byte {
+		b = badgeRunPattern.ReplaceAllFunc(b, func(run []byte) []byte {
+			if mode == BadgesRemove {
+				return []byte("\x00")
+			}
+
+			var links []string
+			for _, m := range badgePattern.FindAllSubmatch(run, -1) {
+				alt := strings.TrimSpace(string(m[1]))
+				if alt == "" {
+					alt = "badge"
+				}
+				links = append(links, "["+alt+"]("+string(m[2])+")")
+			}
+			return []byte(strings.Join(links, " · "))
+		})
+		if mode == BadgesRemove {
+			b = badgeLinePattern.ReplaceAll(b, nil)
+			b = bytes.ReplaceAll(b, []byte("\x00"), nil)
+		}
+		return b
+	})
+}
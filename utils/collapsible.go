@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+// Default markers of the collapsible shorthand understood by
+// ExpandCollapsibles.
+const (
+	DefaultCollapsibleOpen  = ">>>"
+	DefaultCollapsibleClose = "<<<"
+)
+
+// ExpandCollapsibles converts collapsible blocks written as
+//
+//	>>> Summary
+//	Hidden content.
+//	<<<
+//
+// into HTML <details> sections with the text after the opening marker, if
+// any, as their <summary>. Blocks may be nested, markers in fenced code are
+// ignored and markers without a counterpart are left as-is.
+func ExpandCollapsibles(content []byte) []byte {
+	return ExpandCollapsiblesWithMarkers(content, DefaultCollapsibleOpen, DefaultCollapsibleClose)
+}
+
+// ExpandCollapsiblesWithMarkers is like ExpandCollapsibles but uses the given
+// opening and closing markers.
+func ExpandCollapsiblesWithMarkers(content []byte, openMarker, closeMarker string) []byte {
+	if openMarker == "" || closeMarker == "" {
+		return content
+	}
+
+	type marker struct {
+		line    int
+		summary string
+	}
+
+	// pair up opening and closing marker lines outside of code
+	var lines [][]byte
+	var stack []marker
+	replacements := make(map[int]string)
+	walkLines(content, func(line []byte, _ int, inCode bool) {
+		i := len(lines)
+		lines = append(lines, line)
+		if inCode {
+			return
+		}
+
+		trimmed := strings.TrimSpace(string(line))
+		if trimmed == closeMarker {
+			if len(stack) > 0 {
+				m := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				replacements[m.line] = "<details>\n\n"
+				if m.summary != "" {
+					replacements[m.line] = "<details>\n<summary>" + html.EscapeString(m.summary) + "</summary>\n\n"
+				}
+				replacements[i] = "\n</details>\n"
+			}
+			return
+		}
+		if rest, ok := strings.CutPrefix(trimmed, openMarker); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			stack = append(stack, marker{line: i, summary: strings.TrimSpace(rest)})
+		}
+	})
+
+	if len(replacements) == 0 {
+		return content
+	}
+
+	var out bytes.Buffer
+	for i, line := range lines {
+		if r, ok := replacements[i]; ok {
+			out.WriteString(r)
+			continue
+		}
+		out.Write(line)
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"hash/fnv"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// DefaultTagPalette is the palette ColorForString picks from. Every color
+// has a contrast ratio of at least 3:1 with both black and white, so it
+// stays readable on dark and light backgrounds.
+var DefaultTagPalette = []lipgloss.Color{
+	"#E0564B", "#C66A3A", "#C27C0E", "#8A8F2E",
+	"#5FA33A", "#3E9E5F", "#2E9E8F", "#4F96C9",
+	"#2F8FD8", "#6C7BE0", "#A463D6", "#D4589F",
+}
+
+// ColorForString returns a color from DefaultTagPalette chosen by hashing
+// s, so the same tag always gets the same color.
+func ColorForString(s string) lipgloss.Color {
+	return ColorForStringWithPalette(s, DefaultTagPalette)
+}
+
+// ColorForStringWithPalette is like ColorForString but picks from palette,
+// falling back to DefaultTagPalette if it is empty. Pick mid-tone colors to
+// keep the contrast against both backgrounds.
+func ColorForStringWithPalette(s string, palette []lipgloss.Color) lipgloss.Color {
+	if len(palette) == 0 {
+		palette = DefaultTagPalette
+	}
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return palette[h.Sum32()%uint32(len(palette))]
+}
@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConcatOptions configures Concat.
+type ConcatOptions struct {
+	// Separator is inserted between files, e.g. a thematic break.
+	Separator string
+
+	// TitleHeadings inserts a level 1 heading with each file's title before
+	// its contents. The title comes from the frontmatter, falling back to the
+	// file name.
+	TitleHeadings bool
+
+	// ShiftHeadings moves the headings of every file by this many levels,
+	// e.g. 1 to nest them under the title headings.
+	ShiftHeadings int
+}
+
+// Concat combines the markdown files at paths into a single document, with
+// each file's frontmatter removed.
+func Concat(paths []string, opts ConcatOptions) ([]byte, error) {
+	var out bytes.Buffer
+	for i, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", path, err)
+		}
+
+		if i > 0 {
+			out.WriteString("\n")
+			if opts.Separator != "" {
+				out.WriteString(opts.Separator + "\n\n")
+			}
+		}
+
+		if opts.TitleHeadings {
+			vars, _ := extractFrontmatterVars(content)
+			title := vars["title"]
+			if title == "" {
+				title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			}
+			out.WriteString("# " + title + "\n\n")
+		}
+
+		body := ShiftHeadings(RemoveFrontmatter(content), opts.ShiftHeadings)
+		out.Write(bytes.TrimRight(body, "\n"))
+		out.WriteString("\n")
+	}
+	return out.Bytes(), nil
+}
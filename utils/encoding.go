@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// charsetPattern finds a charset declaration such as <meta charset="..."> or
+// a frontmatter charset: / encoding: key near the start of a file.
+var charsetPattern = regexp.MustCompile(`(?i)\b(?:charset|encoding)\s*[=:]\s*["']?([a-z0-9_.:-]+)`)
+
+// charsetSniffLength is how far into a file charsetPattern looks.
+const charsetSniffLength = 1024
+
+// ToUTF8 converts content to UTF-8 so it can be preprocessed and rendered.
+// UTF-8 and UTF-16 byte order marks are honored, as is a charset declared
+// near the start of the file. Valid UTF-8 is returned unchanged, minus any
+// byte order mark, and anything else is assumed to be Windows-1252, a
+// superset of Latin-1.
+func ToUTF8(content []byte) ([]byte, error) {
+	var enc encoding.Encoding
+	switch {
+	case bytes.HasPrefix(content, []byte{0xef, 0xbb, 0xbf}):
+		return content[3:], nil
+	case bytes.HasPrefix(content, []byte{0xff, 0xfe}), bytes.HasPrefix(content, []byte{0xfe, 0xff}):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	case looksLikeUTF16(content, 1):
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case looksLikeUTF16(content, 0):
+		enc = unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	case utf8.Valid(content):
+		return content, nil
+	default:
+		enc = charmap.Windows1252
+		if m := charsetPattern.FindSubmatch(content[:min(len(content), charsetSniffLength)]); m != nil {
+			if declared, err := htmlindex.Get(string(m[1])); err == nil {
+				enc = declared
+			}
+		}
+	}
+
+	b, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert to UTF-8: %w", err)
+	}
+	return b, nil
+}
+
+// readFileUTF8 reads the file at path and converts it to UTF-8 like
+// ToUTF8, so injected, included and sidecar files are read the same way as
+// the document itself.
+func readFileUTF8(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ToUTF8(b)
+}
+
+// looksLikeUTF16 reports whether content is probably BOM-less UTF-16 text,
+// i.e. mostly ASCII with a zero byte at every other position starting at
+// zeroAt: 1 for little endian and 0 for big endian.
+func looksLikeUTF16(content []byte, zeroAt int) bool {
+	sample := content[:min(len(content), charsetSniffLength)]
+	if len(sample) < 4 || len(sample)%2 != 0 {
+		return false
+	}
+	zeros := 0
+	for i := zeroAt; i < len(sample); i += 2 {
+		if sample[i] == 0 {
+			zeros++
+		}
+	}
+	return zeros*10 >= len(sample)/2*9
+}
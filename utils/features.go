@@ -0,0 +1,42 @@
+package utils
+
+import "regexp"
+
+// Features reports the markdown features a document uses.
+type Features struct {
+	HasTables      bool
+	HasTaskLists   bool
+	HasFootnotes   bool
+	HasMath        bool
+	HasFrontmatter bool
+	HasHTML        bool
+}
+
+var (
+	taskListPattern = regexp.MustCompile(`(?m)^[ \t]*(?:[-*+]|\d+[.)])[ \t]+\[[ xX]\][ \t]`)
+	// htmlTagPattern matches HTML tags and comments but not autolinks such
+	// as <https://example.com>.
+	htmlTagPattern = regexp.MustCompile(`</?[A-Za-z][A-Za-z0-9-]*(?:\s[^<>\n]*)?/?>|<!--`)
+)
+
+// DetectFeatures reports which markdown features content uses, for example
+// to tell whether glamour can render it faithfully. Code is ignored, so a
+// table in a fenced block or a tag in a code span doesn't count.
+func DetectFeatures(content []byte) Features {
+	body := RemoveFrontmatter(content)
+	features := Features{HasFrontmatter: len(body) < len(content)}
+
+	protected, _ := ProtectMath(body)
+	features.HasMath = mathPlaceholderPattern.Match(protected)
+
+	var prose []byte
+	mapOutsideFences(body, func(b []byte) []byte {
+		prose = append(prose, codeSpanPattern.ReplaceAll(b, nil)...)
+		return b
+	})
+	features.HasTables = len(findTables(prose)) > 0
+	features.HasTaskLists = taskListPattern.Match(prose)
+	features.HasFootnotes = footnoteRefPattern.Match(prose)
+	features.HasHTML = htmlTagPattern.Match(prose)
+	return features
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// placeholderPattern matches {{ key }} optionally followed by pipe filters,
+// e.g. {{ count | plural:minute,minutes }}.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([^{}|]+?)\s*((?:\|[^{}|]*)*)\}\}`)
+
+// filterFunc transforms a placeholder value. arg is whatever follows the
+// colon in the filter, or the empty string.
+type filterFunc func(value, arg string) (string, bool)
+
+var placeholderFilters = map[string]filterFunc{
+	"plural": pluralFilter,
+	"number": numberFilter,
+	"escape": func(value, _ string) (string, bool) { return EscapeMarkdown(value), true },
+}
+
+// substituteVars replaces every known placeholder in content with its value,
+// running it through any filters. Unknown keys and filters are left as-is.
+func substituteVars(content []byte, vars map[string]string) []byte {
+	return substitutePlaceholders(content, func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	})
+}
+
+// substitutePasses runs substitutePlaceholders up to passes times, so values
+// that contain placeholders themselves get expanded too. It stops early once
+// the content is stable or repeats an earlier pass, as variables referring
+// to each other would.
+func substitutePasses(content []byte, resolve func(key string) (string, bool), passes int) []byte {
+	seen := make(map[string]bool)
+	for range max(1, passes) {
+		seen[string(content)] = true
+		content = substitutePlaceholders(content, resolve)
+		if seen[string(content)] {
+			break
+		}
+	}
+	return content
+}
+
+// substitutePlaceholders is like substituteVars but looks keys up through
+// resolve.
+func substitutePlaceholders(content []byte, resolve func(key string) (string, bool)) []byte {
+	return placeholderPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		m := placeholderPattern.FindSubmatch(match)
+		value, ok := resolve(string(m[1]))
+		if !ok {
+			return match
+		}
+		value, ok = applyFilters(value, string(m[2]))
+		if !ok {
+			return match
+		}
+		return []byte(value)
+	})
+}
+
+// applyFilters runs value through a chain like "| plural:a,b | other".
+func applyFilters(value, chain string) (string, bool) {
+	if chain == "" {
+		return value, true
+	}
+	for _, f := range strings.Split(chain, "|")[1:] {
+		name, arg, _ := strings.Cut(strings.TrimSpace(f), ":")
+		fn, ok := placeholderFilters[strings.TrimSpace(name)]
+		if !ok {
+			return "", false
+		}
+		if value, ok = fn(value, strings.TrimSpace(arg)); !ok {
+			return "", false
+		}
+	}
+	return value, true
+}
+
+// pluralFilter picks the singular form for a count of exactly one and the
+// plural form otherwise. A # in the chosen form is replaced by the count, so
+// "plural:# minute,# minutes" renders "1 minute" or "3 minutes".
+func pluralFilter(value, arg string) (string, bool) {
+	one, other, ok := strings.Cut(arg, ",")
+	if !ok {
+		return "", false
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return "", false
+	}
+	form := other
+	if n == 1 {
+		form = one
+	}
+	return strings.ReplaceAll(strings.TrimSpace(form), "#", strings.TrimSpace(value)), true
+}
+
+// numberFilter groups the integer digits of a number in thousands, so
+// 1234567.5 renders as 1,234,567.5. The fractional part is kept as written.
+func numberFilter(value, _ string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return "", false
+	}
+
+	sign := ""
+	if value[0] == '-' || value[0] == '+' {
+		sign, value = value[:1], value[1:]
+	}
+	intPart, frac, hasFrac := strings.Cut(value, ".")
+	if strings.TrimLeft(intPart, "0123456789") != "" {
+		return "", false
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if hasFrac {
+		b.WriteString("." + frac)
+	}
+	return b.String(), true
+}
@@ -0,0 +1,57 @@
+package utils
+
+import "testing"
+
+func TestSubstituteVars(t *testing.T) {
+	vars := map[string]string{
+		"one":   "1",
+		"three": "3",
+		"name":  "glow",
+		"total": "1234567",
+		"neg":   "-98765.4321",
+		"small": "999",
+		"price": "1234.50",
+		"input": "*bold* [link](x)",
+	}
+
+	for in, want := range map[string]string{
+		"{{ name }}":                             "glow",
+		"{{name}}":                               "glow",
+		"{{ missing }}":                          "{{ missing }}",
+		"{{ one | plural:minute,minutes }}":      "minute",
+		"{{ three | plural:minute,minutes }}":    "minutes",
+		"{{ three | plural:# minute,# minutes}}": "3 minutes",
+		"{{ name | plural:a,b }}":                "{{ name | plural:a,b }}",
+		"{{ name | nonexistent }}":               "{{ name | nonexistent }}",
+		"{{ total | number }}":                   "1,234,567",
+		"{{ neg | number }}":                     "-98,765.4321",
+		"{{ small | number }}":                   "999",
+		"{{ price | number }}":                   "1,234.50",
+		"{{ name | number }}":                    "{{ name | number }}",
+		"{{ input | escape }}":                   `\*bold\* \[link\]\(x\)`,
+	} {
+		t.Run(in, func(t *testing.T) {
+			if got := string(substituteVars([]byte(in), vars)); got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	for in, want := range map[string]string{
+		"*bold*":     `\*bold\*`,
+		"snake_case": `snake\_case`,
+		"`code`":     "\\`code\\`",
+		"[link]":     `\[link\]`,
+		"# heading":  `\# heading`,
+		"a | b":      `a \| b`,
+		"plain text": "plain text",
+	} {
+		t.Run(in, func(t *testing.T) {
+			if got := EscapeMarkdown(in); got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+		})
+	}
+}
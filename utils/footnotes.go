@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+)
+
+var (
+	footnoteRefPattern = regexp.MustCompile(`\[\^([^\]\s]+)\]`)
+	footnoteDefPattern = regexp.MustCompile(`^ {0,3}\[\^([^\]\s]+)\]:[ \t]?`)
+
+	// footnoteRefOrCodeSpanPattern matches code spans, which are skipped,
+	// and footnote references.
+	footnoteRefOrCodeSpanPattern = regexp.MustCompile(codeSpanPattern.String() + "|" + footnoteRefPattern.String())
+)
+
+// footnoteDef is a footnote definition and its continuation lines.
+type footnoteDef struct {
+	label string
+	body  []byte
+}
+
+// NormalizeFootnotes renumbers footnote references sequentially in the order
+// they first appear and moves all definitions to the end of the document in
+// that same order. Named footnotes are renumbered too, consistently across
+// every reference to them.
+func NormalizeFootnotes(content []byte) []byte {
+	var defs []footnoteDef
+	content = mapOutsideFences(content, func(b []byte) []byte {
+		var out bytes.Buffer
+		var cur *footnoteDef
+		for _, line := range splitLines(b) {
+			if m := footnoteDefPattern.FindSubmatch(line); m != nil {
+				defs = append(defs, footnoteDef{label: string(m[1]), body: line[len(m[0]):]})
+				cur = &defs[len(defs)-1]
+				continue
+			}
+			// indented lines continue the previous definition
+			if cur != nil && (len(bytes.TrimSpace(line)) == 0 || bytes.HasPrefix(line, []byte("    ")) || line[0] == '\t') {
+				cur.body = append(cur.body, line...)
+				continue
+			}
+			cur = nil
+			out.Write(line)
+		}
+		return out.Bytes()
+	})
+
+	if len(defs) == 0 {
+		return content
+	}
+
+	numbers := make(map[string]int)
+	number := func(label string) string {
+		if _, ok := numbers[label]; !ok {
+			numbers[label] = len(numbers) + 1
+		}
+		return strconv.Itoa(numbers[label])
+	}
+	renumber := func(b []byte) []byte {
+		return footnoteRefOrCodeSpanPattern.ReplaceAllFunc(b, func(match []byte) []byte {
+			if match[0] == '`' {
+				return match
+			}
+			return []byte("[^" + number(string(footnoteRefOrCodeSpanPattern.FindSubmatch(match)[1])) + "]")
+		})
+	}
+	content = mapOutsideFences(content, renumber)
+
+	// definitions nobody references are numbered after the rest
+	byNumber := make(map[string][]byte)
+	for _, d := range defs {
+		n := number(d.label)
+		if _, dup := byNumber[n]; !dup {
+			byNumber[n] = renumber(bytes.TrimRight(d.body, "\n"))
+		}
+	}
+
+	out := append(bytes.TrimRight(content, "\n"), '\n', '\n')
+	for i := 1; i <= len(numbers); i++ {
+		n := strconv.Itoa(i)
+		if def, ok := byNumber[n]; ok {
+			out = append(out, "[^"+n+"]: "...)
+			out = append(out, def...)
+			out = append(out, '\n')
+		}
+	}
+	return out
+}
@@ -0,0 +1,767 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// sidecarExtensions are tried in order when looking for a sidecar file.
+var sidecarExtensions = []string{".yaml", ".yml"}
+
+// LoadSidecarFrontmatter reads the metadata kept next to a markdown file in
+// <path>.yaml or <path>.yml and flattens it like inline frontmatter. It
+// returns nil without an error when there is no sidecar file.
+func LoadSidecarFrontmatter(mdPath string) (map[string]string, error) {
+	for _, ext := range sidecarExtensions {
+		b, err := readFileUTF8(mdPath + ext)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read sidecar file: %w", err)
+		}
+
+		var raw map[string]interface{}
+		if err := yaml.Unmarshal(b, &raw); err != nil {
+			return nil, fmt.Errorf("unable to parse sidecar file %s: %w", mdPath+ext, err)
+		}
+		vars := make(map[string]string)
+		flattenYAML("", raw, vars)
+		return vars, nil
+	}
+	return nil, nil
+}
+
+// maxInheritanceDepth caps how many files an extends chain may span.
+const maxInheritanceDepth = 16
+
+// ResolveFrontmatterInheritance returns the flattened frontmatter of the
+// markdown file at path merged on top of the frontmatter of the file named
+// by its extends key, which is resolved relative to path and may extend
+// another file in turn. Keys set closer to path win.
+func ResolveFrontmatterInheritance(path string) (map[string]string, error) {
+	var chain []string
+	vars := make(map[string]string)
+	for path != "" {
+		canonical := canonicalPath(path)
+		if slices.Contains(chain, canonical) {
+			return nil, fmt.Errorf("frontmatter inheritance cycle: %s", strings.Join(append(chain, canonical), " -> "))
+		}
+		if len(chain) >= maxInheritanceDepth {
+			return nil, fmt.Errorf("frontmatter inheritance deeper than %d files: %s", maxInheritanceDepth, strings.Join(chain, " -> "))
+		}
+		chain = append(chain, canonical)
+
+		content, err := readFileUTF8(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read file: %w", err)
+		}
+		fileVars, _, err := ExtractFrontmatterVarsE(content)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse frontmatter of %s: %w", path, err)
+		}
+		for k, v := range fileVars {
+			if _, ok := vars[k]; !ok {
+				vars[k] = v
+			}
+		}
+
+		next := strings.TrimSpace(fileVars["extends"])
+		if next != "" && !filepath.IsAbs(next) {
+			next = filepath.Join(filepath.Dir(path), next)
+		}
+		path = next
+	}
+	return vars, nil
+}
+
+// SplitFrontmatter separates a markdown file into its parsed frontmatter and
+// its body. YAML, TOML and JSON frontmatter are understood, as told apart by
+// FrontmatterKind. The frontmatter is nil when the file has none.
+func SplitFrontmatter(content []byte) (map[string]interface{}, []byte, error) {
+	var (
+		raw map[string]interface{}
+		err error
+	)
+	switch FrontmatterKind(content) {
+	case "toml":
+		m := tomlFrontmatterPattern.FindSubmatch(content)
+		err = toml.Unmarshal(m[1], &raw)
+		content = content[len(m[0]):]
+	case "json":
+		m := jsonFrontmatterPattern.Find(content)
+		err = json.Unmarshal(m, &raw)
+		content = content[len(m):]
+	default:
+		raw, _, err = parseFrontmatter(content)
+		content = RemoveFrontmatter(content)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse frontmatter: %w", err)
+	}
+	return raw, content, nil
+}
+
+// SerializeFrontmatter encodes vars as a ---fenced YAML frontmatter block.
+// Keys are sorted at every level so that re-saving a file gives a stable
+// diff.
+func SerializeFrontmatter(vars map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	if len(vars) > 0 {
+		// yaml.v3 always emits map keys in sorted order
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(vars); err != nil {
+			return nil, fmt.Errorf("unable to encode frontmatter: %w", err)
+		}
+		if err := enc.Close(); err != nil {
+			return nil, fmt.Errorf("unable to encode frontmatter: %w", err)
+		}
+	}
+	buf.WriteString("---\n")
+	return buf.Bytes(), nil
+}
+
+var (
+	tomlFrontmatterPattern = regexp.MustCompile(`\A\+\+\+\r?\n((?s:.*?))\r?\n\+\+\+[ \t]*(?:\r?\n|\z)`)
+	jsonFrontmatterPattern = regexp.MustCompile(`\A\{\s*\r?\n(?s:.*?)\r?\n\}[ \t]*(?:\r?\n|\z)`)
+)
+
+// FrontmatterKind reports how the frontmatter of content is delimited:
+// "yaml" for ---, "toml" for +++ and "json" for a JSON object at the very
+// start, as Hugo supports. It returns an empty string when there is no
+// frontmatter.
+func FrontmatterKind(content []byte) string {
+	switch {
+	case detectFrontmatter(content)[0] == 0:
+		return "yaml"
+	case tomlFrontmatterPattern.Match(content):
+		return "toml"
+	case jsonFrontmatterPattern.Match(content):
+		return "json"
+	}
+	return ""
+}
+
+// SerializeFrontmatterAs is like SerializeFrontmatter but encodes vars in
+// the given kind of frontmatter, as returned by FrontmatterKind, so a file
+// can be saved in the style it was read in. An empty kind means YAML.
+func SerializeFrontmatterAs(vars map[string]interface{}, kind string) ([]byte, error) {
+	switch kind {
+	case "", "yaml":
+		return SerializeFrontmatter(vars)
+	case "toml":
+		b, err := toml.Marshal(vars)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode frontmatter: %w", err)
+		}
+		return append(append([]byte("+++\n"), b...), "+++\n"...), nil
+	case "json":
+		if vars == nil {
+			vars = map[string]interface{}{}
+		}
+		b, err := json.MarshalIndent(jsonValue(vars), "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode frontmatter: %w", err)
+		}
+		return append(b, '\n'), nil
+	}
+	return nil, fmt.Errorf("unknown frontmatter kind %q", kind)
+}
+
+// FrontmatterAsCodeBlock replaces the frontmatter of a markdown file with a
+// YAML code block showing it, which is handy when debugging templates.
+// Content without frontmatter is returned unchanged.
+func FrontmatterAsCodeBlock(content []byte) []byte {
+	bounds := detectFrontmatter(content)
+	if bounds[0] != 0 {
+		return content
+	}
+
+	fm := content[bounds[0]:bounds[1]]
+	fm = fm[bytes.IndexByte(fm, '\n')+1:]
+	if end := bytes.LastIndex(fm, []byte("---")); end >= 0 {
+		fm = fm[:end]
+	}
+	fm = append(bytes.TrimSpace(fm), '\n')
+
+	block := WrapCodeBlock(string(fm), "yaml")
+	return append([]byte(block+"\n\n"), content[bounds[1]:]...)
+}
+
+// ExtractFrontmatterVarsE is like the frontmatter parsing done during
+// preprocessing, but returns the YAML error instead of silently ignoring
+// invalid frontmatter. Tab indentation, which YAML forbids, is called out.
+func ExtractFrontmatterVarsE(content []byte) (map[string]string, []int, error) {
+	raw, bounds, err := parseFrontmatter(content)
+	if err != nil {
+		if line := frontmatterTabLine(content[bounds[0]:bounds[1]]); line > 0 {
+			return nil, bounds, fmt.Errorf("frontmatter YAML invalid: found tab indentation on line %d: %w", line, err)
+		}
+		return nil, bounds, fmt.Errorf("frontmatter YAML invalid: %w", err)
+	}
+
+	vars := make(map[string]string)
+	flattenYAML("", raw, vars)
+	return vars, bounds, nil
+}
+
+// frontmatterTabLine returns the 1-based line number of the first line of fm
+// that is indented with a tab, or 0 if there is none.
+func frontmatterTabLine(fm []byte) int {
+	for i, line := range bytes.Split(fm, []byte("\n")) {
+		if bytes.HasPrefix(bytes.TrimLeft(line, " "), []byte("\t")) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// FrontmatterKeys returns the sorted, flattened key names of the frontmatter
+// in content, e.g. "author.name" for a nested author mapping.
+func FrontmatterKeys(content []byte) []string {
+	vars, _ := extractFrontmatterVars(content)
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SnakeCaseKeyPattern matches snake_case key names. LintFrontmatterKeys
+// uses it when given no pattern.
+var SnakeCaseKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9]*(?:_[a-z0-9]+)*$`)
+
+// LintFrontmatterKeys returns the sorted, flattened frontmatter keys of
+// content that break the naming convention pattern, or SnakeCaseKeyPattern
+// if it is nil. Every part of a nested key such as "author.name" has to
+// match.
+func LintFrontmatterKeys(content []byte, pattern *regexp.Regexp) []string {
+	if pattern == nil {
+		pattern = SnakeCaseKeyPattern
+	}
+
+	var bad []string
+	for _, key := range FrontmatterKeys(content) {
+		for _, part := range strings.Split(key, ".") {
+			if !pattern.MatchString(part) {
+				bad = append(bad, key)
+				break
+			}
+		}
+	}
+	return bad
+}
+
+// FrontmatterJSON returns the frontmatter of content as a JSON object,
+// keeping the types YAML parsed it into. It returns {} when there is no
+// frontmatter.
+func FrontmatterJSON(content []byte) ([]byte, error) {
+	raw, _, err := parseFrontmatter(content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse frontmatter: %w", err)
+	}
+	if raw == nil {
+		raw = map[string]interface{}{}
+	}
+
+	b, err := json.Marshal(jsonValue(raw))
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal frontmatter: %w", err)
+	}
+	return b, nil
+}
+
+// jsonValue converts YAML maps with non-string keys, which encoding/json
+// can't marshal, into maps keyed by the keys' string form.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[k] = jsonValue(val)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[fmt.Sprint(k)] = jsonValue(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = jsonValue(val)
+		}
+		return out
+	}
+	return v
+}
+
+// ApplyFrontmatterAliases copies the values of alias keys in vars to their
+// canonical keys, so that, given {"by": "author"}, {{ author }} resolves for
+// files that only set by. aliases maps alias keys to canonical keys.
+// Canonical keys that are already set are never overwritten; when several
+// aliases of a key are set, the alphabetically first one wins.
+func ApplyFrontmatterAliases(vars map[string]string, aliases map[string]string) {
+	names := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+
+	for _, alias := range names {
+		value, ok := vars[alias]
+		if !ok {
+			continue
+		}
+		if _, set := vars[aliases[alias]]; !set {
+			vars[aliases[alias]] = value
+		}
+	}
+}
+
+// FrontmatterAliases returns the aliases listed in the frontmatter of
+// content, e.g. old paths a static site generator should redirect from. A
+// single string counts as a list of one. It returns nil when there are none.
+func FrontmatterAliases(content []byte) []string {
+	raw, _, _ := parseFrontmatter(content)
+	switch v := raw["aliases"].(type) {
+	case string:
+		if v = strings.TrimSpace(v); v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		var aliases []string
+		for _, item := range v {
+			if s := strings.TrimSpace(scalarToString(item)); s != "" {
+				aliases = append(aliases, s)
+			}
+		}
+		return aliases
+	}
+	return nil
+}
+
+// DocumentID returns a stable ID for a markdown file. In order of
+// precedence it is the frontmatter id, the frontmatter slug, the slugified
+// frontmatter title, or finally a hash of the whole content.
+func DocumentID(content []byte) string {
+	vars, _ := extractFrontmatterVars(content)
+	for _, key := range []string{"id", "slug"} {
+		if v := strings.TrimSpace(vars[key]); v != "" {
+			return v
+		}
+	}
+	if slug := Slugify(vars["title"]); slug != "" {
+		return slug
+	}
+
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
+
+// maxFilenameSlug caps the length in bytes of the name SuggestFilename
+// derives from a title, leaving room for the extension.
+const maxFilenameSlug = 100
+
+// windowsReservedNames are the file names Windows refuses whatever the
+// extension.
+var windowsReservedNames = regexp.MustCompile(`^(?i:con|prn|aux|nul|com[1-9]|lpt[1-9])$`)
+
+var repeatedHyphens = regexp.MustCompile(`-{2,}`)
+
+// SuggestFilename returns a file name for a markdown document: the slugified
+// frontmatter slug or title with a .md extension, or the current date and
+// time if neither gives a name. Repeated hyphens are collapsed and those at
+// either end trimmed, long names are shortened and names Windows reserves,
+// such as con, get a suffix, so the result is safe on any platform.
+func SuggestFilename(content []byte) string {
+	vars, _ := extractFrontmatterVars(content)
+	for _, key := range []string{"slug", "title"} {
+		slug := strings.Trim(repeatedHyphens.ReplaceAllString(Slugify(vars[key]), "-"), "-_")
+		if len(slug) > maxFilenameSlug {
+			slug = strings.ToValidUTF8(slug[:maxFilenameSlug], "")
+			slug = strings.TrimRight(slug, "-_")
+		}
+		if slug == "" {
+			continue
+		}
+		if windowsReservedNames.MatchString(slug) {
+			slug += "-note"
+		}
+		return slug + ".md"
+	}
+	return time.Now().Format("2006-01-02-150405") + ".md"
+}
+
+// RenderPathTemplate fills in the placeholders of an output path template
+// such as "{{year}}/{{slug}}.html" from the flattened frontmatter vars. On
+// top of the frontmatter, year, month and day are taken from the date
+// variable when it is a date ParseFlexibleDate understands. Placeholder
+// filters work as in documents. A placeholder whose variable is missing or
+// empty is an error, so no partial path is produced.
+func RenderPathTemplate(tmpl string, vars map[string]string) (string, error) {
+	pathVars := make(map[string]string, len(vars)+3)
+	if t, ok := ParseFlexibleDate(vars["date"]); ok {
+		pathVars["year"] = t.Format("2006")
+		pathVars["month"] = t.Format("01")
+		pathVars["day"] = t.Format("02")
+	}
+	for k, v := range vars {
+		pathVars[k] = v
+	}
+
+	var missing []string
+	path := substitutePlaceholders([]byte(tmpl), func(key string) (string, bool) {
+		v, ok := pathVars[key]
+		if !ok || strings.TrimSpace(v) == "" {
+			missing = append(missing, key)
+			return "", false
+		}
+		return v, true
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("unable to render path %q: %s not set", tmpl, strings.Join(missing, ", "))
+	}
+	if m := placeholderPattern.Find(path); m != nil {
+		return "", fmt.Errorf("unable to render path %q: invalid filter in %s", tmpl, m)
+	}
+	return string(path), nil
+}
+
+// WeightFromFrontmatter returns the frontmatter weight, or order if there is
+// no weight, for sorting documents the way static site generators do. ok is
+// false if neither is set to an integer, so callers can fall back to
+// sorting by filename.
+func WeightFromFrontmatter(content []byte) (weight int, ok bool) {
+	vars, _ := extractFrontmatterVars(content)
+	for _, key := range []string{"weight", "order"} {
+		if weight, ok := VarInt(vars, key); ok {
+			return weight, true
+		}
+	}
+	return 0, false
+}
+
+// VarBool reports whether the variable key is set to a true value such as
+// "true", "yes", "on" or "1". Missing and unparseable values are false.
+func VarBool(vars map[string]string, key string) bool {
+	switch strings.ToLower(strings.TrimSpace(vars[key])) {
+	case "true", "yes", "y", "on", "1":
+		return true
+	default:
+		return false
+	}
+}
+
+// VarInt returns the variable key as an integer. ok is false if the variable
+// is missing or not an integer.
+func VarInt(vars map[string]string, key string) (int, bool) {
+	v, ok := vars[key]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// VarTime parses the variable key with the given layout, or any layout
+// ParseFlexibleDate knows if layout is empty. Unquoted YAML timestamps are
+// flattened to RFC 3339, so that layout is accepted as well. ok is false if
+// the variable is missing or can't be parsed.
+func VarTime(vars map[string]string, key, layout string) (time.Time, bool) {
+	v, ok := vars[key]
+	if !ok {
+		return time.Time{}, false
+	}
+	if layout == "" {
+		return ParseFlexibleDate(v)
+	}
+	v = strings.TrimSpace(v)
+	for _, l := range []string{layout, time.RFC3339} {
+		if t, err := time.Parse(l, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// flexibleDateLayouts are the layouts ParseFlexibleDate tries, in order.
+var flexibleDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"Jan 2 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// ParseFlexibleDate parses s with the first of the common date layouts that
+// fits, such as 2020-01-01, 2020-01-01T10:00:00Z and Jan 2, 2020. Dates
+// without a zone are in UTC. ok is false if no layout fits.
+func ParseFlexibleDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range flexibleDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ValidateDates checks that every one of keys set in the frontmatter of
+// content parses as a date with layout, returning an error for each that
+// doesn't. Like VarTime, unquoted YAML timestamps are accepted as well, and
+// an empty layout accepts anything ParseFlexibleDate does. Missing keys
+// aren't reported.
+func ValidateDates(content []byte, keys []string, layout string) []error {
+	vars, _ := extractFrontmatterVars(content)
+
+	var errs []error
+	for _, key := range keys {
+		v, ok := vars[key]
+		if !ok {
+			continue
+		}
+		if _, ok := VarTime(vars, key, layout); !ok {
+			if layout == "" {
+				errs = append(errs, fmt.Errorf("frontmatter %s: %q is not a valid date", key, v))
+				continue
+			}
+			_, err := time.Parse(layout, strings.TrimSpace(v))
+			errs = append(errs, fmt.Errorf("frontmatter %s: %q is not a valid date: %w", key, v, err))
+		}
+	}
+	return errs
+}
+
+// DefaultMetadataKeys and DefaultMetadataSeparator make up the byline
+// MetadataLine and {{ meta_line }} build by default.
+var DefaultMetadataKeys = []string{"author", "date", "reading_time"}
+
+const DefaultMetadataSeparator = " · "
+
+// MetadataLine builds a byline such as "Jane · 2024-05-01 · 3 min read" from
+// the given frontmatter keys of content, using DefaultMetadataSeparator.
+// reading_time and word_count are computed from the body. Missing and empty
+// keys are skipped.
+func MetadataLine(content []byte, keys []string) string {
+	return MetadataLineWithSeparator(content, keys, DefaultMetadataSeparator)
+}
+
+// MetadataLineWithSeparator is like MetadataLine but joins the parts with
+// separator.
+func MetadataLineWithSeparator(content []byte, keys []string, separator string) string {
+	vars, _ := extractFrontmatterVars(content)
+	body := anyPlaceholderPattern.ReplaceAll(RemoveFrontmatter(content), nil)
+	vars["word_count"] = strconv.Itoa(WordCount(body))
+	vars["reading_time"] = strconv.Itoa(ReadingTime(body))
+	return metadataLine(vars, keys, separator)
+}
+
+// metadataLine joins the values of keys in vars, describing the computed
+// counts in words and shortening YAML dates without a time of day.
+func metadataLine(vars map[string]string, keys []string, separator string) string {
+	var parts []string
+	for _, key := range keys {
+		v := strings.TrimSpace(vars[key])
+		if v == "" {
+			continue
+		}
+		v = displayDate(v)
+		switch key {
+		case "reading_time":
+			v += " min read"
+		case "word_count":
+			v += " words"
+		}
+		parts = append(parts, v)
+	}
+	return strings.Join(parts, separator)
+}
+
+// displayDate shortens an unquoted YAML date, which is flattened to an
+// RFC 3339 timestamp at midnight, back to the date. Other values are
+// returned unchanged.
+func displayDate(v string) string {
+	if t, err := time.Parse(time.RFC3339, v); err == nil && t.Equal(t.Truncate(24*time.Hour)) {
+		return t.Format("2006-01-02")
+	}
+	return v
+}
+
+// Modes understood by TransformFrontmatter.
+const (
+	// FrontmatterStrip removes the frontmatter.
+	FrontmatterStrip = "strip"
+	// FrontmatterKeep leaves the frontmatter in place.
+	FrontmatterKeep = "keep"
+	// FrontmatterPandoc replaces the frontmatter with a pandoc title block
+	// built from its title, author and date.
+	FrontmatterPandoc = "pandoc"
+)
+
+// TransformFrontmatter prepares content for another tool according to mode,
+// which is one of FrontmatterStrip, FrontmatterKeep or FrontmatterPandoc.
+// Unknown modes strip the frontmatter, as glow does before rendering. In a
+// pandoc title block several authors are separated by semicolons and fields
+// that aren't set are left empty.
+func TransformFrontmatter(content []byte, mode string) []byte {
+	switch mode {
+	case FrontmatterKeep:
+		return content
+	case FrontmatterPandoc:
+		return pandocTitleBlock(content)
+	default:
+		return RemoveFrontmatter(content)
+	}
+}
+
+// pandocTitleBlock replaces the frontmatter of content with a pandoc title
+// block.
+func pandocTitleBlock(content []byte) []byte {
+	raw, _, _ := parseFrontmatter(content)
+	vars := make(map[string]string)
+	flattenYAML("", raw, vars)
+	author := vars["author"]
+	if authors, ok := raw["author"].([]interface{}); ok {
+		parts := make([]string, len(authors))
+		for i, a := range authors {
+			parts[i] = scalarToString(a)
+		}
+		author = strings.Join(parts, "; ")
+	} else if author == "" {
+		author = vars["author.name"]
+	}
+
+	fields := []string{vars["title"], author, displayDate(strings.TrimSpace(vars["date"]))}
+	for len(fields) > 0 && strings.TrimSpace(fields[len(fields)-1]) == "" {
+		fields = fields[:len(fields)-1]
+	}
+	body := bytes.TrimLeft(RemoveFrontmatter(content), "\n")
+	if len(fields) == 0 {
+		return body
+	}
+
+	var block strings.Builder
+	for _, f := range fields {
+		// continuation lines of a field are indented
+		f = strings.ReplaceAll(strings.TrimSpace(f), "\n", "\n  ")
+		block.WriteString(strings.TrimRight("% "+f, " ") + "\n")
+	}
+	block.WriteString("\n")
+	return append([]byte(block.String()), body...)
+}
+
+// PromoteFrontmatter strips the frontmatter of a markdown file but renders
+// the given keys into a header at the top of the body using template, e.g.
+// "# {{title}}\n*{{date}}*". Listed keys missing from the frontmatter render
+// as empty strings.
+func PromoteFrontmatter(content []byte, keys []string, template string) []byte {
+	vars, _ := extractFrontmatterVars(content)
+	promoted := make(map[string]string, len(keys))
+	for _, k := range keys {
+		promoted[k] = vars[k]
+	}
+
+	header := bytes.TrimRight(substituteVars([]byte(template), promoted), "\n")
+	body := bytes.TrimLeft(RemoveFrontmatter(content), "\n")
+	return append(append(header, '\n', '\n'), body...)
+}
+
+// PrependSummary puts the frontmatter summary, or description if there is
+// no summary, in a blockquote under the document's title, or at the top of
+// the body if it doesn't open with a level 1 heading. Documents without a
+// summary, or whose body already contains it, are returned unchanged.
+func PrependSummary(content []byte) []byte {
+	vars, _ := extractFrontmatterVars(content)
+	summary := strings.TrimSpace(vars["summary"])
+	if summary == "" {
+		summary = strings.TrimSpace(vars["description"])
+	}
+	body := RemoveFrontmatter(content)
+	normalize := func(s string) string { return strings.Join(strings.Fields(s), " ") }
+	if summary == "" || strings.Contains(normalize(string(ToPlainText(body))), normalize(string(ToPlainText([]byte(summary))))) {
+		return content
+	}
+
+	frontmatter := content[:len(content)-len(body)]
+	out := append([]byte{}, frontmatter...)
+	if headings := findHeadings(body); len(headings) > 0 && headings[0].level == 1 &&
+		len(bytes.TrimSpace(body[:headings[0].start])) == 0 {
+		out = append(out, bytes.TrimLeft(body[:headings[0].end], "\n")...)
+		out = append(bytes.TrimRight(out, "\n"), "\n\n"...)
+		body = body[headings[0].end:]
+	}
+	for _, line := range strings.Split(summary, "\n") {
+		out = append(out, strings.TrimRight("> "+line, " ")+"\n"...)
+	}
+	if body = bytes.TrimLeft(body, "\n"); len(body) > 0 {
+		out = append(append(out, '\n'), body...)
+	}
+	return out
+}
+
+// DefaultDraftBannerText is the banner DraftBanner puts above drafts.
+const DefaultDraftBannerText = "⚠ DRAFT"
+
+// DraftBannerOptions configures DraftBannerWithOptions.
+type DraftBannerOptions struct {
+	// Text is the banner text. Defaults to DefaultDraftBannerText.
+	Text string
+	// Hide leaves drafts without a banner.
+	Hide bool
+}
+
+// DraftBanner puts a banner quote at the top of the body when the
+// frontmatter sets draft to a true value. Other documents are returned
+// unchanged.
+func DraftBanner(content []byte) []byte {
+	return DraftBannerWithOptions(content, DraftBannerOptions{})
+}
+
+// DraftBannerWithOptions is like DraftBanner but lets the caller change the
+// banner text or turn the banner off.
+func DraftBannerWithOptions(content []byte, opts DraftBannerOptions) []byte {
+	vars, _ := extractFrontmatterVars(content)
+	if opts.Hide || !VarBool(vars, "draft") {
+		return content
+	}
+	text := opts.Text
+	if text == "" {
+		text = DefaultDraftBannerText
+	}
+
+	body := RemoveFrontmatter(content)
+	out := append([]byte{}, content[:len(content)-len(body)]...)
+	out = append(out, "> **"+EscapeMarkdown(text)+"**\n\n"...)
+	return append(out, bytes.TrimLeft(body, "\n")...)
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndentedFrontmatterFences(t *testing.T) {
+	for name, tc := range map[string]struct {
+		in    string
+		title string
+	}{
+		"no indent":      {"---\ntitle: Doc\n---\nbody", "Doc"},
+		"one space":      {" ---\ntitle: Doc\n ---\nbody", "Doc"},
+		"two spaces":     {"  ---\ntitle: Doc\n  ---\nbody", "Doc"},
+		"three spaces":   {"   ---\ntitle: Doc\n   ---\nbody", "Doc"},
+		"mixed":          {"  ---\ntitle: Doc\n---\nbody", "Doc"},
+		"four spaces":    {"    ---\ntitle: Doc\n    ---\nbody", ""},
+		"indented close": {"---\ntitle: Doc\n    ---\nbody", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			vars, _ := extractFrontmatterVars([]byte(tc.in))
+			if vars["title"] != tc.title {
+				t.Errorf("expected title %q, got %q", tc.title, vars["title"])
+			}
+
+			body := string(RemoveFrontmatter([]byte(tc.in)))
+			if tc.title != "" && body != "body" {
+				t.Errorf("expected the frontmatter to be removed, got %q", body)
+			}
+			if tc.title == "" && !strings.Contains(body, "title: Doc") {
+				t.Errorf("expected the content to be left alone, got %q", body)
+			}
+		})
+	}
+}
+
+func TestRemoveFrontmatterTidy(t *testing.T) {
+	for name, tc := range map[string]struct {
+		in, want string
+	}{
+		"no blank line":   {"---\ntitle: Doc\n---\n# Heading\n", "# Heading\n"},
+		"one blank line":  {"---\ntitle: Doc\n---\n\n# Heading\n", "# Heading\n"},
+		"two blank lines": {"---\ntitle: Doc\n---\n\n\n# Heading\n", "\n# Heading\n"},
+		"crlf":            {"---\r\ntitle: Doc\r\n---\r\n\r\n# Heading\r\n", "# Heading\r\n"},
+		"no frontmatter":  {"# Heading\n", "# Heading\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := string(RemoveFrontmatterTidy([]byte(tc.in))); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestThematicBreakIsNotFrontmatter(t *testing.T) {
+	for _, name := range []string{"thematic-break.md", "thematic-break-note.md"} {
+		t.Run(name, func(t *testing.T) {
+			content, err := os.ReadFile("testdata/" + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if bounds := detectFrontmatter(content); bounds[0] != -1 {
+				t.Errorf("expected no frontmatter, got %v", bounds)
+			}
+			if body := RemoveFrontmatter(content); !strings.Contains(string(body), "The text between the two rules") {
+				t.Errorf("expected the prose to be kept, got %q", body)
+			}
+		})
+	}
+
+	if _, _, err := ExtractFrontmatterVarsE([]byte("---\ntitle: [unclosed\n---\nbody")); err == nil {
+		t.Error("expected invalid YAML that starts with a key to be reported")
+	}
+}
+
+func TestFrontmatterRoundTrip(t *testing.T) {
+	for kind, in := range map[string]string{
+		"yaml": "---\ntitle: Doc\ntags:\n  - a\n  - b\n---\nbody\n",
+		"toml": "+++\ntitle = \"Doc\"\ntags = [\"a\", \"b\"]\n+++\nbody\n",
+		"json": "{\n  \"title\": \"Doc\",\n  \"tags\": [\"a\", \"b\"]\n}\nbody\n",
+	} {
+		t.Run(kind, func(t *testing.T) {
+			if got := FrontmatterKind([]byte(in)); got != kind {
+				t.Fatalf("expected kind %q, got %q", kind, got)
+			}
+			vars, body, err := SplitFrontmatter([]byte(in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if vars["title"] != "Doc" || string(body) != "body\n" {
+				t.Fatalf("expected the title and body to be split, got %v and %q", vars, body)
+			}
+
+			fm, err := SerializeFrontmatterAs(vars, kind)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := append(fm, body...)
+			if got := FrontmatterKind(out); got != kind {
+				t.Errorf("expected the output to keep kind %q, got %q", kind, got)
+			}
+			again, againBody, err := SplitFrontmatter(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(again, vars) || string(againBody) != string(body) {
+				t.Errorf("expected %v and %q to survive the round trip, got %v and %q", vars, body, again, againBody)
+			}
+		})
+	}
+}
+
+func TestRenderPathTemplate(t *testing.T) {
+	vars := map[string]string{"date": "2020-01-05T00:00:00Z", "slug": "hello"}
+	got, err := RenderPathTemplate("{{year}}/{{month}}/{{ slug }}.html", vars)
+	if err != nil || got != "2020/01/hello.html" {
+		t.Errorf("expected 2020/01/hello.html, got %q (%v)", got, err)
+	}
+
+	if got, err := RenderPathTemplate("{{year}}/{{title}}.html", vars); err == nil || !strings.Contains(err.Error(), "title") {
+		t.Errorf("expected an error naming the missing title, got %q (%v)", got, err)
+	}
+}
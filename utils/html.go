@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// htmlExtensions are the GitHub flavored extensions glamour supports, plus
+// footnotes.
+var htmlExtensions = goldmark.WithExtensions(extension.GFM, extension.Footnote)
+
+var (
+	// htmlRenderer omits raw HTML from the output.
+	htmlRenderer = goldmark.New(htmlExtensions)
+	// unsafeHTMLRenderer passes raw HTML through.
+	unsafeHTMLRenderer = goldmark.New(htmlExtensions, goldmark.WithRendererOptions(html.WithUnsafe()))
+)
+
+// HTMLOptions configures RenderHTMLWithOptions.
+type HTMLOptions struct {
+	// Dir is the directory injected and included files are resolved
+	// against, normally the document's. Defaults to the working directory.
+	Dir string
+
+	// Preprocess configures how the dynamic text is expanded.
+	Preprocess PreprocessOptions
+
+	// UnsafeHTML passes raw HTML such as <details> through. It is off by
+	// default, and ignored in Preprocess.SafeMode, since raw HTML can run
+	// scripts in the browser showing the document.
+	UnsafeHTML bool
+}
+
+// RenderHTML preprocesses content like the terminal renderer does,
+// stripping the frontmatter and expanding dynamic text, and converts the
+// result to HTML. Raw HTML in the document is omitted from the output.
+func RenderHTML(content []byte) ([]byte, error) {
+	return RenderHTMLWithOptions(content, HTMLOptions{})
+}
+
+// RenderHTMLWithOptions is like RenderHTML but lets the caller set the
+// document's directory, tune the preprocessing and keep raw HTML.
+func RenderHTMLWithOptions(content []byte, opts HTMLOptions) ([]byte, error) {
+	dir := opts.Dir
+	if dir == "" {
+		dir = "."
+	}
+	content = PreprocessDynamicTextWithOptions(content, dir, map[string]bool{}, opts.Preprocess)
+
+	renderer := htmlRenderer
+	if opts.UnsafeHTML && !opts.Preprocess.SafeMode {
+		renderer = unsafeHTMLRenderer
+	}
+
+	var buf bytes.Buffer
+	if err := renderer.Convert(content, &buf); err != nil {
+		return nil, fmt.Errorf("unable to render HTML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Modes understood by RenderImages.
+const (
+	// ImagesText replaces images with their alt text.
+	ImagesText = "text"
+	// ImagesLink turns images into hyperlinks to their source on terminals
+	// that support OSC 8 hyperlinks, and into markdown links elsewhere.
+	ImagesLink = "link"
+	// ImagesInline shows local images inline on terminals that speak the
+	// iTerm2 or kitty image protocols, and falls back to ImagesLink
+	// elsewhere.
+	ImagesInline = "inline"
+)
+
+// imageOrCodeSpanPattern matches code spans and inline links, which are
+// skipped, and inline images.
+var imageOrCodeSpanPattern = regexp.MustCompile(codeSpanPattern.String() + "|" + inlineLinkPattern.String())
+
+// RenderImages rewrites the inline images in content according to mode,
+// which is one of ImagesText, ImagesLink or ImagesInline. Terminal support
+// is detected from the environment. Images in code are left alone, as are
+// all images for unknown modes.
+func RenderImages(content []byte, mode string) []byte {
+	if mode != ImagesText && mode != ImagesLink && mode != ImagesInline {
+		return content
+	}
+	protocol := ""
+	if mode == ImagesInline {
+		protocol = terminalImageProtocol()
+	}
+	hyperlinks := terminalSupportsHyperlinks()
+
+	return mapOutsideFences(content, func(b []byte) []byte {
+		return imageOrCodeSpanPattern.ReplaceAllFunc(b, func(match []byte) []byte {
+			m := imageOrCodeSpanPattern.FindSubmatch(match)
+			if len(m[1]) == 0 {
+				return match
+			}
+			alt, src := string(m[2]), string(m[3])
+			if alt == "" {
+				alt = "image"
+			}
+
+			switch {
+			case mode == ImagesText:
+				return []byte(alt)
+			case protocol != "" && !isExternalTarget(src):
+				if img, ok := inlineImage(protocol, src); ok {
+					return []byte(img)
+				}
+			}
+			if hyperlinks {
+				// BEL terminates the sequences since a backslash would be
+				// taken for a markdown escape
+				return []byte("\x1b]8;;" + src + "\a" + alt + "\x1b]8;;\a")
+			}
+			return []byte("[" + alt + "](" + src + ")")
+		})
+	})
+}
+
+// inlineImage returns the escape sequence that shows the local image at
+// path using protocol. ok is false if the file can't be read.
+func inlineImage(protocol, path string) (string, bool) {
+	switch protocol {
+	case "kitty":
+		// kitty reads the file itself; only PNG files are sent this way
+		if _, err := os.Stat(path); err != nil || !strings.EqualFold(filepath.Ext(path), ".png") {
+			return "", false
+		}
+		return "\x1b_Ga=T,f=100,t=f;" + base64.StdEncoding.EncodeToString([]byte(path)) + "\x1b\\", true
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false
+		}
+		return "\x1b]1337;File=inline=1;size=" + strconv.Itoa(len(data)) + ":" +
+			base64.StdEncoding.EncodeToString(data) + "\a", true
+	}
+}
+
+// terminalImageProtocol returns the inline image protocol the terminal
+// speaks, "iterm" or "kitty", or the empty string if it speaks neither.
+func terminalImageProtocol() string {
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "", strings.Contains(os.Getenv("TERM"), "kitty"),
+		os.Getenv("TERM_PROGRAM") == "ghostty":
+		return "kitty"
+	case os.Getenv("TERM_PROGRAM") == "iTerm.app", os.Getenv("TERM_PROGRAM") == "WezTerm":
+		return "iterm"
+	default:
+		return ""
+	}
+}
+
+// terminalSupportsHyperlinks reports whether the terminal is known to
+// support OSC 8 hyperlinks.
+func terminalSupportsHyperlinks() bool {
+	if terminalImageProtocol() != "" || os.Getenv("WT_SESSION") != "" {
+		return true
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "vscode", "Hyper":
+		return true
+	}
+	if v, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && v >= 5000 {
+		return true
+	}
+	return false
+}
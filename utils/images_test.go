@@ -0,0 +1,24 @@
+package utils
+
+import "testing"
+
+func TestRenderImages(t *testing.T) {
+	// a terminal without OSC 8 hyperlinks or inline images
+	for _, env := range []string{"KITTY_WINDOW_ID", "TERM", "TERM_PROGRAM", "WT_SESSION", "VTE_VERSION"} {
+		t.Setenv(env, "")
+	}
+
+	in := "![Logo](logo.png \"title\") ![](a.png) [link](x.md) `![code](c.png)`"
+	for mode, want := range map[string]string{
+		ImagesText:   "Logo image [link](x.md) `![code](c.png)`",
+		ImagesLink:   "[Logo](logo.png) [image](a.png) [link](x.md) `![code](c.png)`",
+		ImagesInline: "[Logo](logo.png) [image](a.png) [link](x.md) `![code](c.png)`",
+		"unknown":    in,
+	} {
+		t.Run(mode, func(t *testing.T) {
+			if got := string(RenderImages([]byte(in), mode)); got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// IndexOptions configures BuildIndexWithOptions.
+type IndexOptions struct {
+	// SkipWithoutFrontmatter leaves out files that have no frontmatter.
+	SkipWithoutFrontmatter bool
+}
+
+// BuildIndex reads the files matching glob, in the syntax of filepath.Glob,
+// and returns the flattened frontmatter of each, sorted by path, as the data
+// for index and listing pages. The "path" key of every entry is set to the
+// path of its file, replacing any frontmatter key of that name. Directories
+// are skipped.
+func BuildIndex(glob string) ([]map[string]string, error) {
+	return BuildIndexWithOptions(glob, IndexOptions{})
+}
+
+// BuildIndexWithOptions is like BuildIndex but lets the caller skip files
+// without frontmatter.
+func BuildIndexWithOptions(glob string, opts IndexOptions) ([]map[string]string, error) {
+	paths, err := filepath.Glob(glob)
+	if err != nil {
+		return nil, fmt.Errorf("unable to match %s: %w", glob, err)
+	}
+	sort.Strings(paths)
+
+	var index []map[string]string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", path, err)
+		}
+
+		vars, bounds := extractFrontmatterVars(content)
+		if opts.SkipWithoutFrontmatter && bounds[0] != 0 {
+			continue
+		}
+		vars["path"] = path
+		index = append(index, vars)
+	}
+	return index, nil
+}
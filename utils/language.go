@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// languageTrigrams holds the most frequent letter trigrams of each language
+// DetectProseLanguage can tell apart, most frequent first. An underscore
+// stands for the start or end of a word.
+var languageTrigrams = map[string]string{
+	"en": "_th the he_ _an and nd_ _of of_ _to ing ng_ _in ion tio ati _is is_ ed_ her ent for _fo or_ hat tha at_ ere es_ re_ on_ ter er_ thi his _wh _be _it ly_ all",
+	"de": "en_ er_ _de der ie_ die _di ch_ ich sch ein _ei und _un nd_ cht den ung ng_ gen te_ ine _da das as_ ist _is st_ ter ber in_ nen che _zu zu_ eit auf _au mit _mi",
+	"fr": "es_ _de de_ le_ _le ent _la la_ nt_ les ion que _qu ue_ _et et_ re_ des _co tio ons our _pa ous men ait eme _un une ne_ est _es st_ _po pou dan _da ans eur",
+	"es": "_de de_ os_ la_ _la el_ _el es_ que _qu ue_ _en en_ as_ ent ión ció los _lo ado nte _co con ara par _pa por _po del una _un _es est sta ien dad ida _se se_ ar_",
+	"it": "_di di_ _de del ell lla la_ to_ che _ch he_ re_ ne_ zio ion ent one _co con ato _il il_ per _pe er_ no_ le_ ere _la gli _in are ta_ nte tto ono _un una ia_ _so",
+	"pt": "_de de_ os_ do_ _do da_ _da que _qu ue_ ão_ _co ção çõe ent _pa par com ara as_ _e_ em_ _em _um um_ uma est nte ado dos men ida ade _se se_ não _nã por ra_",
+	"nl": "en_ _de de_ an_ een _ee het _he et_ van _va aar _ge cht ij_ ng_ er_ ver _ve oor ter den ede eer in_ ijk _in _zi zij ijn te_ lij gen nde ie_ iet nie _ni aan _op",
+}
+
+// trigramRanks maps every trigram of languageTrigrams to its weight for each
+// language, which is higher the more frequent it is there.
+var trigramRanks = func() map[string]map[string]int {
+	ranks := make(map[string]map[string]int)
+	for lang, profile := range languageTrigrams {
+		trigrams := strings.Fields(profile)
+		ranks[lang] = make(map[string]int, len(trigrams))
+		for i, t := range trigrams {
+			ranks[lang][t] = len(trigrams) - i
+		}
+	}
+	return ranks
+}()
+
+const (
+	// minLanguageHits is how many trigrams of the best guess must be found
+	// before guessing.
+	minLanguageHits = 20
+	// languageMargin is how far the best guess must be ahead of the next.
+	languageMargin = 1.15
+)
+
+// DetectProseLanguage guesses the ISO 639-1 code of the language the prose
+// of content is written in, by scoring its letter trigrams against the most
+// frequent ones of each language. It only knows a few European languages and
+// returns an empty string when the text is too short or the guess is too
+// close to call.
+func DetectProseLanguage(content []byte) string {
+	words := strings.FieldsFunc(strings.ToLower(string(ToPlainText(RemoveFrontmatter(content)))), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	counts := make(map[string]int)
+	for _, w := range words {
+		padded := []rune("_" + w + "_")
+		for i := 0; i+3 <= len(padded); i++ {
+			counts[string(padded[i:i+3])]++
+		}
+	}
+
+	scores := make(map[string]int)
+	hits := make(map[string]int)
+	langs := make([]string, 0, len(trigramRanks))
+	for lang, ranks := range trigramRanks {
+		for t, n := range counts {
+			if weight, ok := ranks[t]; ok {
+				scores[lang] += n * weight
+				hits[lang] += n
+			}
+		}
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if scores[langs[i]] != scores[langs[j]] {
+			return scores[langs[i]] > scores[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+
+	best, second := langs[0], langs[1]
+	if hits[best] < minLanguageHits || float64(scores[best]) < float64(scores[second])*languageMargin {
+		return ""
+	}
+	return best
+}
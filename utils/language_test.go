@@ -0,0 +1,19 @@
+package utils
+
+import "testing"
+
+func TestDetectProseLanguage(t *testing.T) {
+	for want, in := range map[string]string{
+		"en": "This document explains how the rendering of markdown works and what options there are for the user.",
+		"de": "Dieses Dokument erklärt, wie die Darstellung von Markdown funktioniert und welche Einstellungen es für den Benutzer gibt.",
+		"fr": "Ce document explique comment fonctionne le rendu du markdown et quelles sont les options pour les utilisateurs.",
+		"it": "Questo documento spiega come funziona la visualizzazione del markdown e quali sono le opzioni per gli utenti.",
+		"":   "Short text.",
+	} {
+		t.Run(want, func(t *testing.T) {
+			if got := DetectProseLanguage([]byte("```go\nfunc main() {}\n```\n\n" + in)); got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"strings"
+	"sync"
+)
+
+var extensionLanguagesMu sync.RWMutex
+
+// extensionLanguages maps lower-case file extensions to the language tags
+// of fenced code blocks.
+var extensionLanguages = map[string]string{
+	".bash":  "bash",
+	".c":     "c",
+	".cc":    "cpp",
+	".cpp":   "cpp",
+	".cs":    "csharp",
+	".css":   "css",
+	".go":    "go",
+	".h":     "c",
+	".hpp":   "cpp",
+	".html":  "html",
+	".java":  "java",
+	".js":    "javascript",
+	".json":  "json",
+	".kt":    "kotlin",
+	".lua":   "lua",
+	".md":    "markdown",
+	".php":   "php",
+	".pl":    "perl",
+	".py":    "python",
+	".rb":    "ruby",
+	".rs":    "rust",
+	".sh":    "sh",
+	".sql":   "sql",
+	".swift": "swift",
+	".toml":  "toml",
+	".ts":    "typescript",
+	".tsx":   "tsx",
+	".xml":   "xml",
+	".yaml":  "yaml",
+	".yml":   "yaml",
+	".zsh":   "zsh",
+}
+
+// LanguageForExtension returns the language tag for code in files with the
+// extension ext, such as "python" for ".py", or the empty string if it isn't
+// known. The leading dot is optional and case doesn't matter.
+func LanguageForExtension(ext string) string {
+	extensionLanguagesMu.RLock()
+	defer extensionLanguagesMu.RUnlock()
+	return extensionLanguages[normalizeExtension(ext)]
+}
+
+// RegisterLanguage maps the file extension ext to the language tag lang,
+// replacing any existing mapping, so LanguageForExtension and the features
+// built on it know about it.
+func RegisterLanguage(ext, lang string) {
+	extensionLanguagesMu.Lock()
+	defer extensionLanguagesMu.Unlock()
+	extensionLanguages[normalizeExtension(ext)] = lang
+}
+
+// normalizeExtension lower-cases ext and makes sure it starts with a dot.
+func normalizeExtension(ext string) string {
+	return "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+}
@@ -0,0 +1,302 @@
+package utils
+
+import (
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Kinds of links reported by ExtractLinks.
+const (
+	// LinkRelative is a path relative to the document.
+	LinkRelative = "relative"
+	// LinkAbsolute is a URL or a path from the root.
+	LinkAbsolute = "absolute"
+	// LinkAnchor is a #fragment within the document.
+	LinkAnchor = "anchor"
+)
+
+// Link is a link or image found in a document.
+type Link struct {
+	Text  string // the link text or alt text
+	Href  string // the target, with reference links resolved
+	Kind  string // LinkRelative, LinkAbsolute or LinkAnchor
+	Image bool
+}
+
+var (
+	inlineLinkPattern = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*<?([^)\s>]*)>?(?:\s+(?:"[^"]*"|'[^']*'))?\s*\)`)
+	codeSpanPattern   = regexp.MustCompile("`+[^`]*`+")
+	urlSchemePattern  = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+	htmlAnchorPattern = regexp.MustCompile(`<a\s[^>]*\b(?:name|id)\s*=\s*["']([^"']+)["']`)
+
+	// linkPattern matches, in order of preference, code spans, inline links,
+	// link definitions and reference links such as [text][label], [text][]
+	// and [label].
+	linkPattern = regexp.MustCompile("(?m)`+[^`]*`+|" + inlineLinkPattern.String() +
+		`|^ {0,3}\[[^\]]+\]:.*$|(!?)\[([^\]^][^\]]*)\](?:\[([^\]]*)\])?`)
+)
+
+// findLinks returns the inline and reference links and images in content in
+// order, skipping fenced code and code spans. Reference links whose label
+// isn't defined are not links and are left out.
+func findLinks(content []byte) []Link {
+	defs := linkDefinitions(content)
+	var links []Link
+	mapOutsideFences(content, func(b []byte) []byte {
+		for _, m := range linkPattern.FindAllSubmatch(b, -1) {
+			switch {
+			case m[3] != nil:
+				links = append(links, newLink(string(m[2]), string(m[3]), len(m[1]) > 0))
+			case m[5] != nil:
+				label := string(m[6])
+				if label == "" {
+					label = string(m[5])
+				}
+				if href, ok := defs[strings.ToLower(label)]; ok {
+					links = append(links, newLink(string(m[5]), href, len(m[4]) > 0))
+				}
+			}
+		}
+		return b
+	})
+	return links
+}
+
+// newLink returns the link to href, classified by its kind.
+func newLink(text, href string, image bool) Link {
+	kind := LinkRelative
+	switch {
+	case strings.HasPrefix(href, "#"):
+		kind = LinkAnchor
+	case isExternalTarget(href) || strings.HasPrefix(href, "/"):
+		kind = LinkAbsolute
+	}
+	return Link{Text: text, Href: href, Kind: kind, Image: image}
+}
+
+// ExtractLinks returns the links and images in content, reference links
+// resolved to their definitions, skipping code. Each target is only
+// reported once, with the text of its first link.
+func ExtractLinks(content []byte) []Link {
+	seen := make(map[string]bool)
+	var links []Link
+	for _, l := range findLinks(content) {
+		if seen[l.Href] {
+			continue
+		}
+		seen[l.Href] = true
+		links = append(links, l)
+	}
+	return links
+}
+
+// isExternalTarget reports whether a link target is a URL rather than a path.
+func isExternalTarget(target string) bool {
+	return urlSchemePattern.MatchString(target) || strings.HasPrefix(target, "//")
+}
+
+// BrokenLink is a relative link whose target doesn't exist.
+type BrokenLink struct {
+	Text   string
+	Target string
+}
+
+// CheckLocalLinks returns the relative links and images in content whose
+// targets don't exist under baseDir. URLs and anchors are skipped.
+func CheckLocalLinks(content []byte, baseDir string) []BrokenLink {
+	var broken []BrokenLink
+	for _, l := range findLinks(content) {
+		if l.Href == "" || l.Kind == LinkAnchor || isExternalTarget(l.Href) {
+			continue
+		}
+
+		p, _, _ := strings.Cut(l.Href, "#")
+		p, _, _ = strings.Cut(p, "?")
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			p = unescaped
+		}
+		if _, err := os.Stat(filepath.Join(baseDir, filepath.FromSlash(p))); err != nil {
+			broken = append(broken, BrokenLink{Text: l.Text, Target: l.Href})
+		}
+	}
+	return broken
+}
+
+// CheckAnchors returns the #anchors linked from content that don't match any
+// heading or <a name> / <a id> anchor in it.
+func CheckAnchors(content []byte) []string {
+	known := make(map[string]bool)
+	for _, slug := range headingSlugs(content) {
+		known[slug] = true
+	}
+	mapOutsideFences(content, func(b []byte) []byte {
+		for _, m := range htmlAnchorPattern.FindAllSubmatch(b, -1) {
+			known[string(m[1])] = true
+		}
+		return b
+	})
+
+	var missing []string
+	reported := make(map[string]bool)
+	for _, l := range findLinks(content) {
+		anchor, ok := strings.CutPrefix(l.Href, "#")
+		if !ok || anchor == "" || known[anchor] || reported[anchor] {
+			continue
+		}
+		if unescaped, err := url.PathUnescape(anchor); err == nil && known[unescaped] {
+			continue
+		}
+		reported[anchor] = true
+		missing = append(missing, anchor)
+	}
+	return missing
+}
+
+// autolinkPattern matches the spans AutolinkURLs must leave alone (code
+// spans, links, HTML tags and link definitions) as well as bare URLs, which
+// are captured by the last group.
+var autolinkPattern = regexp.MustCompile("(?m)`+[^`]*`+" +
+	`|!?\[[^\]]*\]\([^)]*\)|<[^>\n]+>|^ {0,3}\[[^\]]+\]:.*$` +
+	`|(https?://[^\s<>\[\]]+)`)
+
+// AutolinkURLs wraps bare http(s) URLs in angle brackets so they render as
+// links. URLs in code, existing links and HTML are left alone, and trailing
+// punctuation such as a sentence's final period stays outside the link.
+func AutolinkURLs(content []byte) []byte {
+	return mapOutsideFences(content, func(b []byte) []byte {
+		return autolinkPattern.ReplaceAllFunc(b, func(match []byte) []byte {
+			if !bytes.HasPrefix(match, []byte("http")) {
+				return match
+			}
+			u, rest := trimURLPunctuation(match)
+			return append(append([]byte("<"), u...), append([]byte(">"), rest...)...)
+		})
+	})
+}
+
+// trimURLPunctuation splits trailing punctuation and unbalanced closing
+// parentheses off a URL.
+func trimURLPunctuation(u []byte) ([]byte, []byte) {
+	end := len(u)
+	for end > 0 {
+		c := u[end-1]
+		if bytes.IndexByte([]byte(`.,:;!?'"*_`), c) >= 0 {
+			end--
+			continue
+		}
+		if c == ')' && bytes.Count(u[:end], []byte("(")) < bytes.Count(u[:end], []byte(")")) {
+			end--
+			continue
+		}
+		break
+	}
+	return u[:end], u[end:]
+}
+
+var (
+	linkDefinitionPattern = regexp.MustCompile(`(?m)^ {0,3}\[([^\]]+)\]:[ \t]*<?([^\s>]+)>?`)
+	imagePattern          = regexp.MustCompile(`!\[([^\]]*)\](?:\(\s*<?([^)\s>]*)>?(?:\s+(?:"[^"]*"|'[^']*'))?\s*\)|\[([^\]]*)\])?`)
+)
+
+// linkDefinitions returns the reference link definitions in content, keyed
+// by their lower-cased label.
+func linkDefinitions(content []byte) map[string]string {
+	defs := make(map[string]string)
+	mapOutsideFences(content, func(b []byte) []byte {
+		for _, m := range linkDefinitionPattern.FindAllSubmatch(b, -1) {
+			label := strings.ToLower(string(m[1]))
+			if _, ok := defs[label]; !ok {
+				defs[label] = string(m[2])
+			}
+		}
+		return b
+	})
+	return defs
+}
+
+// FirstImage returns the alt text and source of the first image in content,
+// skipping code. Both inline images and reference-style images whose label
+// is defined in content are found.
+func FirstImage(content []byte) (alt, src string, ok bool) {
+	defs := linkDefinitions(content)
+	mapOutsideFences(content, func(b []byte) []byte {
+		if ok {
+			return b
+		}
+		prose := codeSpanPattern.ReplaceAll(b, nil)
+		for _, m := range imagePattern.FindAllSubmatch(prose, -1) {
+			switch {
+			case m[2] != nil:
+				alt, src, ok = string(m[1]), string(m[2]), true
+			default:
+				// ![alt][label], ![alt][] and ![alt] all refer to a definition
+				label := string(m[3])
+				if label == "" {
+					label = string(m[1])
+				}
+				src, ok = defs[strings.ToLower(label)]
+				alt = string(m[1])
+			}
+			if ok {
+				break
+			}
+		}
+		return b
+	})
+	if !ok {
+		return "", "", false
+	}
+	return alt, src, true
+}
+
+// superscriptDigits are the superscript forms of 0 to 9.
+var superscriptDigits = []rune("⁰¹²³⁴⁵⁶⁷⁸⁹")
+
+// superscript returns n in superscript digits.
+func superscript(n int) string {
+	var b strings.Builder
+	for _, d := range strconv.Itoa(n) {
+		b.WriteRune(superscriptDigits[d-'0'])
+	}
+	return b.String()
+}
+
+// FootnoteLinks turns the inline links in content into their text followed
+// by a superscript number, and lists the numbered targets in a references
+// section at the end, for output where links can't be followed. Links to
+// the same target share a number. Anchors, images and links in code are
+// left alone.
+func FootnoteLinks(content []byte) []byte {
+	numbers := make(map[string]int)
+	var targets []string
+	content = mapOutsideFences(content, func(b []byte) []byte {
+		return linkPattern.ReplaceAllFunc(b, func(match []byte) []byte {
+			m := linkPattern.FindSubmatch(match)
+			if m[3] == nil || len(m[1]) > 0 || len(m[3]) == 0 || m[3][0] == '#' {
+				return match
+			}
+			target := string(m[3])
+			n, ok := numbers[target]
+			if !ok {
+				targets = append(targets, target)
+				n = len(targets)
+				numbers[target] = n
+			}
+			return append(append([]byte{}, m[2]...), superscript(n)...)
+		})
+	})
+	if len(targets) == 0 {
+		return content
+	}
+
+	out := append(bytes.TrimRight(content, "\n"), "\n\n**References**\n\n"...)
+	for i, target := range targets {
+		out = append(out, strconv.Itoa(i+1)+". "+target+"\n"...)
+	}
+	return out
+}
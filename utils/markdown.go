@@ -0,0 +1,424 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// codeFence describes a fenced code block by its byte offsets.
+type codeFence struct {
+	start, end int    // from the opening fence line to after the closing fence line
+	marker     []byte // the opening run of backticks or tildes
+	lang       string // first word of the info string
+	closed     bool
+}
+
+var fenceOpenPattern = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^ \t\r\n]*)")
+
+// splitLines splits content into lines, keeping the trailing newlines so the
+// pieces can be joined back together unchanged.
+func splitLines(content []byte) [][]byte {
+	return bytes.SplitAfter(content, []byte("\n"))
+}
+
+// findFences returns the fenced code blocks in content. A fence that is never
+// closed runs to the end of the document.
+func findFences(content []byte) []codeFence {
+	var fences []codeFence
+	var cur *codeFence
+	offset := 0
+
+	for _, line := range splitLines(content) {
+		if cur == nil {
+			if m := fenceOpenPattern.FindSubmatch(line); m != nil {
+				// backtick fences may not contain backticks in their info string
+				if m[1][0] == '`' && bytes.ContainsRune(line[len(m[0]):], '`') {
+					offset += len(line)
+					continue
+				}
+				cur = &codeFence{start: offset, marker: m[1], lang: string(m[2])}
+			}
+		} else if isClosingFence(line, cur.marker) {
+			cur.end = offset + len(line)
+			cur.closed = true
+			fences = append(fences, *cur)
+			cur = nil
+		}
+		offset += len(line)
+	}
+
+	if cur != nil {
+		cur.end = len(content)
+		fences = append(fences, *cur)
+	}
+	return fences
+}
+
+// isClosingFence reports whether line closes a fence opened with marker.
+func isClosingFence(line, marker []byte) bool {
+	trimmed := bytes.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return false
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == marker[0] {
+		n++
+	}
+	return n >= len(marker) && len(bytes.TrimSpace(trimmed[n:])) == 0
+}
+
+// mapOutsideFences applies fn to every part of content that is not inside a
+// fenced code block and returns the reassembled document.
+func mapOutsideFences(content []byte, fn func([]byte) []byte) []byte {
+	var out bytes.Buffer
+	last := 0
+	for _, f := range findFences(content) {
+		out.Write(fn(content[last:f.start]))
+		out.Write(content[f.start:f.end])
+		last = f.end
+	}
+	out.Write(fn(content[last:]))
+	return out.Bytes()
+}
+
+// DominantLanguage returns the most common language tag among the fenced code
+// blocks in content, counting blocks rather than lines. Ties go to the
+// language that reached the count first. It returns an empty string if no
+// block has a tag.
+func DominantLanguage(content []byte) string {
+	counts := make(map[string]int)
+	var best string
+	for _, f := range findFences(content) {
+		lang := strings.ToLower(f.lang)
+		if lang == "" {
+			continue
+		}
+		counts[lang]++
+		if best == "" || counts[lang] > counts[best] {
+			best = lang
+		}
+	}
+	return best
+}
+
+// walkLines calls fn for every line of content along with its byte offset
+// and whether it belongs to a fenced code block, fence lines included.
+func walkLines(content []byte, fn func(line []byte, offset int, inCode bool)) {
+	fences := findFences(content)
+	offset := 0
+	for _, line := range splitLines(content) {
+		for len(fences) > 0 && fences[0].end <= offset {
+			fences = fences[1:]
+		}
+		fn(line, offset, len(fences) > 0 && fences[0].start <= offset)
+		offset += len(line)
+	}
+}
+
+// heading is an ATX or Setext heading found in a document.
+type heading struct {
+	level      int
+	text       string
+	start, end int  // byte offsets of the heading's first and after its last line
+	setext     bool // whether the heading is underlined rather than prefixed with #
+}
+
+var (
+	atxHeadingPattern      = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*\r?\n?$`)
+	setextUnderlinePattern = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*\r?\n?$`)
+	// notTermPattern matches a line that starts something other than a
+	// paragraph: a heading, quote, table row or list item.
+	notTermPattern = regexp.MustCompile(`^\s*([#>|]|[-*+][ \t]|\d+[.)][ \t])`)
+)
+
+// findHeadings returns the headings in content, skipping frontmatter and
+// fenced code. The text of a Setext heading spans every line of the
+// paragraph above its underline.
+func findHeadings(content []byte) []heading {
+	skip := 0
+	if bounds := detectFrontmatter(content); bounds[0] == 0 {
+		skip = bounds[1]
+	}
+
+	var headings []heading
+	var para []string // the lines of the current paragraph
+	paraStart := 0
+	inBlock := false // inside a list, quote or other non-paragraph block
+	walkLines(content, func(line []byte, offset int, inCode bool) {
+		trimmed := strings.TrimSpace(string(line))
+		switch {
+		case offset < skip || inCode || trimmed == "":
+			para, inBlock = nil, false
+		case atxHeadingPattern.Match(line):
+			m := atxHeadingPattern.FindSubmatch(line)
+			headings = append(headings, heading{
+				level: len(m[1]),
+				text:  string(m[2]),
+				start: offset,
+				end:   offset + len(line),
+			})
+			para, inBlock = nil, false
+		case len(para) > 0 && setextUnderlinePattern.Match(line):
+			level := 1
+			if trimmed[0] == '-' {
+				level = 2
+			}
+			headings = append(headings, heading{
+				level:  level,
+				text:   strings.Join(para, " "),
+				start:  paraStart,
+				end:    offset + len(line),
+				setext: true,
+			})
+			para = nil
+		case inBlock || len(para) == 0 && (notTermPattern.Match(line) || strings.HasPrefix(string(line), "    ") || line[0] == '\t' || setextUnderlinePattern.Match(line)):
+			// lists, quotes, tables, indented code and thematic breaks
+			// aren't paragraphs, and neither are their continuation lines
+			inBlock = true
+		default:
+			if len(para) == 0 {
+				paraStart = offset
+			}
+			para = append(para, trimmed)
+		}
+	})
+	return headings
+}
+
+// headingSlugs returns the anchor of every heading in content. Repeated
+// headings get -1, -2 and so on appended, like GitHub does.
+func headingSlugs(content []byte) []string {
+	seen := make(map[string]int)
+	var slugs []string
+	for _, h := range findHeadings(content) {
+		slug := Slugify(string(ToPlainText([]byte(h.text))))
+		if n := seen[slug]; n > 0 {
+			seen[slug]++
+			slug += "-" + strconv.Itoa(n)
+		} else {
+			seen[slug] = 1
+		}
+		slugs = append(slugs, slug)
+	}
+	return slugs
+}
+
+// DuplicateHeadings returns the headings of content that share an anchor
+// with another heading, keyed by the text of the first one, along with how
+// often they appear. These are the headings headingSlugs gives -1, -2 and
+// so on suffixes. Headings in code are ignored.
+func DuplicateHeadings(content []byte) map[string]int {
+	counts := make(map[string]int)
+	texts := make(map[string]string)
+	for _, h := range findHeadings(content) {
+		slug := Slugify(string(ToPlainText([]byte(h.text))))
+		if _, ok := texts[slug]; !ok {
+			texts[slug] = h.text
+		}
+		counts[slug]++
+	}
+
+	dupes := make(map[string]int)
+	for slug, n := range counts {
+		if n > 1 {
+			dupes[texts[slug]] = n
+		}
+	}
+	return dupes
+}
+
+// ShiftHeadings moves every heading in content by the given number of
+// levels, clamping the result to levels 1 through 6. Headings in fenced code
+// are left alone.
+func ShiftHeadings(content []byte, by int) []byte {
+	if by == 0 {
+		return content
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, h := range findHeadings(content) {
+		level := min(6, max(1, h.level+by))
+		out.Write(content[last:h.start])
+		out.Write(shiftHeading(content[h.start:h.end], h, level))
+		last = h.end
+	}
+	out.Write(content[last:])
+	return out.Bytes()
+}
+
+// shiftHeading rewrites the source of heading h at the given level. Setext
+// headings moved below level 2 become ATX headings.
+func shiftHeading(src []byte, h heading, level int) []byte {
+	if !h.setext {
+		hashes := bytes.IndexByte(src, '#')
+		return append(append(append([]byte{}, src[:hashes]...), strings.Repeat("#", level)...), src[hashes+h.level:]...)
+	}
+
+	eol := lineEnding(src)
+	lines := splitLines(bytes.TrimSuffix(src, []byte(eol)))
+	underline := src[len(src)-len(lines[len(lines)-1])-len(eol):]
+	if level > 2 {
+		return []byte(strings.Repeat("#", level) + " " + h.text + eol)
+	}
+
+	char := "="
+	if level == 2 {
+		char = "-"
+	}
+	indent := len(underline) - len(bytes.TrimLeft(underline, " "))
+	body := src[:len(src)-len(underline)]
+	marks := len(bytes.TrimSpace(underline))
+	return append(append([]byte{}, body...), string(underline[:indent])+strings.Repeat(char, marks)+eol...)
+}
+
+// lineEnding returns the line break at the end of line, if any.
+func lineEnding(line []byte) string {
+	switch {
+	case bytes.HasSuffix(line, []byte("\r\n")):
+		return "\r\n"
+	case bytes.HasSuffix(line, []byte("\n")):
+		return "\n"
+	}
+	return ""
+}
+
+// Section is a part of a document that starts with a heading.
+type Section struct {
+	// Heading is the heading's text, empty for the preamble.
+	Heading string
+	// Level is the heading's level, 0 for the preamble.
+	Level int
+	// Content holds the section's bytes, heading line included.
+	Content []byte
+}
+
+// SplitSections splits content at every heading of the given level or
+// shallower. Anything before the first such heading becomes a preamble
+// section; it is omitted when empty. Joining the contents of all sections
+// gives back the original document.
+func SplitSections(content []byte, level int) []Section {
+	var sections []Section
+	cur := Section{}
+	start := 0
+	for _, h := range findHeadings(content) {
+		if h.level > level {
+			continue
+		}
+		if h.start > start || cur.Level > 0 {
+			cur.Content = content[start:h.start]
+			sections = append(sections, cur)
+		}
+		cur = Section{Heading: h.text, Level: h.level}
+		start = h.start
+	}
+
+	if len(content) > start || cur.Level > 0 {
+		cur.Content = content[start:]
+		sections = append(sections, cur)
+	}
+	return sections
+}
+
+// MergeCodeBlocks joins consecutive fenced code blocks that have the same
+// language tag and fence marker and are separated only by blank lines, as
+// left behind by splicing many includes. The joined parts are separated by
+// a blank line. Untagged blocks and blocks with prose in between are left
+// alone.
+func MergeCodeBlocks(content []byte) []byte {
+	fences := findFences(content)
+	var out bytes.Buffer
+	last := 0
+	for i := 0; i < len(fences); i++ {
+		f := fences[i]
+		j := i
+		for j+1 < len(fences) && mergeableFences(content, fences[j], fences[j+1]) {
+			j++
+		}
+		if j == i {
+			continue
+		}
+
+		out.Write(content[last:f.start])
+		for k := i; k <= j; k++ {
+			lines := splitLines(bytes.TrimSuffix(content[fences[k].start:fences[k].end], []byte("\n")))
+			if k == i {
+				out.Write(lines[0])
+			} else {
+				out.WriteString("\n")
+			}
+			for _, line := range lines[1 : len(lines)-1] {
+				out.Write(line)
+			}
+		}
+		out.Write(content[fences[j].end-len(lastLine(content[:fences[j].end])) : fences[j].end])
+		last = fences[j].end
+		i = j
+	}
+	out.Write(content[last:])
+	return out.Bytes()
+}
+
+// mergeableFences reports whether MergeCodeBlocks may join a and the fence
+// b that follows it.
+func mergeableFences(content []byte, a, b codeFence) bool {
+	return a.closed && b.closed && a.lang != "" &&
+		strings.EqualFold(a.lang, b.lang) && bytes.Equal(a.marker, b.marker) &&
+		len(bytes.TrimSpace(content[a.end:b.start])) == 0
+}
+
+// lastLine returns the last line of b, including its line break.
+func lastLine(b []byte) []byte {
+	return b[bytes.LastIndexByte(bytes.TrimSuffix(b, []byte("\n")), '\n')+1:]
+}
+
+// HasUnclosedFence reports whether content ends inside a fenced code block,
+// as truncated or streamed documents can.
+func HasUnclosedFence(content []byte) bool {
+	fences := findFences(content)
+	return len(fences) > 0 && !fences[len(fences)-1].closed
+}
+
+// CloseFences appends a closing fence matching the opening one, same
+// character and length, when content ends inside a fenced code block, so
+// the rest of the document isn't rendered as code. Other content is
+// returned unchanged.
+func CloseFences(content []byte) []byte {
+	fences := findFences(content)
+	if len(fences) == 0 || fences[len(fences)-1].closed {
+		return content
+	}
+
+	out := append([]byte{}, content...)
+	if len(out) > 0 && out[len(out)-1] != '\n' {
+		out = append(out, '\n')
+	}
+	return append(append(out, fences[len(fences)-1].marker...), '\n')
+}
+
+// ApplyDefaultCodeLang tags the fenced code blocks in content that have no
+// language with lang, so they get syntax highlighting. Documents can set
+// it for themselves with default_lang in their frontmatter. Fences that
+// already name a language are left alone.
+func ApplyDefaultCodeLang(content []byte, lang string) []byte {
+	lang = strings.TrimSpace(lang)
+	if lang == "" {
+		return content
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, f := range findFences(content) {
+		if f.lang != "" {
+			continue
+		}
+		at := f.start + bytes.Index(content[f.start:], f.marker) + len(f.marker)
+		out.Write(content[last:at])
+		out.WriteString(lang)
+		last = at
+	}
+	out.Write(content[last:])
+	return out.Bytes()
+}
@@ -0,0 +1,54 @@
+package utils
+
+import "testing"
+
+func TestSetextHeadings(t *testing.T) {
+	content := []byte("---\ntitle: Doc\n---\nIntro\n=====\n\n- item\n  continued\n---\n\n```\nCode\n---\n```\n\nDetails\n-------\n")
+
+	headings := findHeadings(content)
+	if len(headings) != 2 {
+		t.Fatalf("expected 2 headings, got %+v", headings)
+	}
+	if h := headings[0]; h.text != "Intro" || h.level != 1 {
+		t.Errorf("expected level 1 heading Intro, got %+v", h)
+	}
+	if h := headings[1]; h.text != "Details" || h.level != 2 {
+		t.Errorf("expected level 2 heading Details, got %+v", h)
+	}
+
+	want := "---\ntitle: Doc\n---\nIntro\n-----\n\n- item\n  continued\n---\n\n```\nCode\n---\n```\n\n### Details\n"
+	if got := string(ShiftHeadings(content, 1)); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestDetectFrontmatterIgnoresSetext(t *testing.T) {
+	if bounds := detectFrontmatter([]byte("Title\n---\nBody\n---\n")); bounds[0] != -1 {
+		t.Errorf("expected no frontmatter, got %v", bounds)
+	}
+}
+
+func TestNormalizeFootnotes(t *testing.T) {
+	for name, tc := range map[string]struct {
+		in, want string
+	}{
+		"renumbered": {
+			"One[^b] two[^a] again[^b].\n\n[^a]: First.\n[^b]: Second.\n",
+			"One[^1] two[^2] again[^1].\n\n[^1]: Second.\n[^2]: First.\n",
+		},
+		"code span": {
+			"Write `[^1]` for a note[^x].\n\n[^x]: Like this.\n",
+			"Write `[^1]` for a note[^1].\n\n[^1]: Like this.\n",
+		},
+		"code span first": {
+			"See `[^x]` and[^y].\n\n[^y]: Y.\n",
+			"See `[^x]` and[^1].\n\n[^1]: Y.\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := string(NormalizeFootnotes([]byte(tc.in))); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
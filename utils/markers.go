@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultMarkers are the task markers FindMarkers looks for when none are
+// given.
+var DefaultMarkers = []string{"TODO", "FIXME", "NOTE"}
+
+// DefaultTodoMarkers are the markers {{ todo_count }} counts. NOTE is left
+// out since a note isn't a task.
+var DefaultTodoMarkers = []string{"TODO", "FIXME"}
+
+// Marker is a task marker such as TODO found in a document.
+type Marker struct {
+	Line   int    // 1-based line number
+	Marker string // the marker as written
+	Text   string // the rest of the line, without a trailing comment closer
+}
+
+// FindMarkers returns the task markers in content, in prose and code alike.
+// A marker must be a whole word and at most one is reported per line. Nil
+// markers means DefaultMarkers.
+func FindMarkers(content []byte, markers []string) []Marker {
+	return findMarkers(content, markers, false)
+}
+
+// FindMarkersFold is like FindMarkers but matches markers regardless of
+// case.
+func FindMarkersFold(content []byte, markers []string) []Marker {
+	return findMarkers(content, markers, true)
+}
+
+func findMarkers(content []byte, markers []string, fold bool) []Marker {
+	if markers == nil {
+		markers = DefaultMarkers
+	}
+	if len(markers) == 0 {
+		return nil
+	}
+
+	quoted := make([]string, len(markers))
+	for i, m := range markers {
+		quoted[i] = regexp.QuoteMeta(m)
+	}
+	expr := `\b(` + strings.Join(quoted, "|") + `)\b(?:\([^)]*\))?[:\s-]*(.*)`
+	if fold {
+		expr = "(?i)" + expr
+	}
+	pattern := regexp.MustCompile(expr)
+
+	var found []Marker
+	for i, line := range splitLines(content) {
+		m := pattern.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+		found = append(found, Marker{
+			Line:   i + 1,
+			Marker: string(m[1]),
+			Text:   strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(strings.TrimSpace(string(m[2])), "-->"), "*/")),
+		})
+	}
+	return found
+}
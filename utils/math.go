@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+)
+
+// mathPattern matches code spans, which are skipped, and $$display$$ or
+// $inline$ math. Inline math can't start or end with a space, so prices like
+// "$5 and $10" aren't mistaken for it.
+var mathPattern = regexp.MustCompile("`+[^`]*`+" + `|\$\$[\s\S]+?\$\$|\$[^\s$](?:[^$\n]*?[^\s\\$])?\$`)
+
+var mathPlaceholderPattern = regexp.MustCompile("\x00math:(\\d+)\x00")
+
+// ProtectMath swaps the LaTeX math spans in content for placeholders that
+// no other transformation touches, and returns a function that puts the
+// original math back. Math in code is left alone.
+func ProtectMath(content []byte) ([]byte, func([]byte) []byte) {
+	var spans [][]byte
+	protected := mapOutsideFences(content, func(b []byte) []byte {
+		var out bytes.Buffer
+		last := 0
+		for _, loc := range mathPattern.FindAllIndex(b, -1) {
+			start, end := loc[0], loc[1]
+			span := b[start:end]
+			escaped := start > 0 && b[start-1] == '\\'
+			// "$5 to $7" style text is followed by a digit
+			currency := end < len(b) && b[end] >= '0' && b[end] <= '9'
+			if span[0] == '`' || escaped || currency {
+				continue
+			}
+
+			out.Write(b[last:start])
+			out.WriteString("\x00math:" + strconv.Itoa(len(spans)) + "\x00")
+			spans = append(spans, span)
+			last = end
+		}
+		out.Write(b[last:])
+		return out.Bytes()
+	})
+
+	restore := func(b []byte) []byte {
+		return mathPlaceholderPattern.ReplaceAllFunc(b, func(match []byte) []byte {
+			n, err := strconv.Atoi(string(mathPlaceholderPattern.FindSubmatch(match)[1]))
+			if err != nil || n >= len(spans) {
+				return match
+			}
+			return spans[n]
+		})
+	}
+	return protected, restore
+}
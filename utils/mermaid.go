@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Modes understood by HandleMermaid.
+const (
+	// MermaidKeep leaves mermaid blocks as they are.
+	MermaidKeep = "keep"
+	// MermaidNote replaces mermaid blocks with a note followed by the diagram
+	// source in a collapsed <details> section.
+	MermaidNote = "note"
+	// MermaidOmit replaces mermaid blocks with just the note.
+	MermaidOmit = "omit"
+)
+
+// mermaidNote stands in for an omitted diagram.
+const mermaidNote = "*[Mermaid diagram omitted]*\n"
+
+// HandleMermaid replaces ```mermaid code blocks according to mode, which is
+// one of MermaidKeep, MermaidNote or MermaidOmit. Unknown modes keep the
+// blocks.
+func HandleMermaid(content []byte, mode string) []byte {
+	if mode != MermaidNote && mode != MermaidOmit {
+		return content
+	}
+
+	var out bytes.Buffer
+	last := 0
+	for _, f := range findFences(content) {
+		if !strings.EqualFold(f.lang, "mermaid") {
+			continue
+		}
+		out.Write(content[last:f.start])
+		out.WriteString(mermaidNote)
+		if mode == MermaidNote {
+			out.WriteString("\n<details>\n<summary>Diagram source</summary>\n\n")
+			out.Write(bytes.TrimRight(content[f.start:f.end], "\n"))
+			if !f.closed {
+				out.WriteString("\n" + string(f.marker))
+			}
+			out.WriteString("\n\n</details>\n")
+		}
+		last = f.end
+	}
+	out.Write(content[last:])
+	return out.Bytes()
+}
@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// sgrPattern matches an SGR sequence, which sets colors and text attributes.
+var sgrPattern = regexp.MustCompile(`\x1b\[[0-9;:]*m`)
+
+// Paginate splits rendered output into pages of height rows for a simple
+// pager, wrapping at the terminal's width. See PaginateWidth.
+func Paginate(b []byte, height int) [][]byte {
+	return PaginateWidth(b, TerminalWidth(), height)
+}
+
+// PaginateWidth splits rendered output into pages of exactly height rows, as
+// shown on a terminal width columns wide; only the last page can be
+// shorter. Lines wider than the terminal count as the rows they wrap onto
+// and are split between those rows when they don't fit on a page. Line
+// breaks inside escape sequences aren't line breaks. A page that ends with
+// colors or attributes still in effect resets them, and the next page starts
+// by setting them again.
+func PaginateWidth(b []byte, width, height int) [][]byte {
+	if len(b) == 0 {
+		return nil
+	}
+	width, height = max(1, width), max(1, height)
+
+	var (
+		pages [][]byte
+		page  []byte
+		rows  int
+		state [][]byte // the SGR sequences in effect after the last row
+	)
+	for _, line := range splitRenderedLines(b) {
+		for _, row := range wrapRows(line, width) {
+			if rows == height {
+				if len(state) > 0 {
+					page = append(page, "\x1b[0m"...)
+				}
+				pages = append(pages, page)
+				page, rows = bytes.Join(state, nil), 0
+			}
+			page = append(page, row...)
+			rows++
+
+			for _, sgr := range sgrPattern.FindAll(row, -1) {
+				if bytes.Equal(sgr, []byte("\x1b[m")) || bytes.Equal(sgr, []byte("\x1b[0m")) {
+					state = nil
+					continue
+				}
+				state = append(state, sgr)
+			}
+		}
+	}
+	return append(pages, page)
+}
+
+// wrapRows splits a rendered line into the rows a terminal width columns
+// wide shows it on. A wide rune that doesn't fit at the end of a row moves
+// to the next one, like the terminal does, and escape sequences take up no
+// room.
+func wrapRows(line []byte, width int) [][]byte {
+	var rows [][]byte
+	start, cells := 0, 0
+	escapes := ansiPattern.FindAllIndex(line, -1)
+	for i := 0; i < len(line); {
+		if len(escapes) > 0 && escapes[0][0] == i {
+			i = escapes[0][1]
+			escapes = escapes[1:]
+			continue
+		}
+		r, size := utf8.DecodeRune(line[i:])
+		w := runewidth.RuneWidth(r)
+		if r == '\r' || r == '\n' {
+			w = 0
+		}
+		if cells > 0 && cells+w > width {
+			rows = append(rows, line[start:i])
+			start, cells = i, 0
+		}
+		cells += w
+		i += size
+	}
+	return append(rows, line[start:])
+}
+
+// splitRenderedLines splits b after every line break that isn't part of an
+// escape sequence.
+func splitRenderedLines(b []byte) [][]byte {
+	var lines [][]byte
+	start, skip := 0, 0
+	escapes := ansiPattern.FindAllIndex(b, -1)
+	for i := 0; i < len(b); i++ {
+		for skip < len(escapes) && escapes[skip][1] <= i {
+			skip++
+		}
+		if b[i] != '\n' || skip < len(escapes) && escapes[skip][0] <= i {
+			continue
+		}
+		lines = append(lines, b[start:i+1])
+		start = i + 1
+	}
+	if start < len(b) {
+		lines = append(lines, b[start:])
+	}
+	return lines
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPaginateWidth(t *testing.T) {
+	for name, tc := range map[string]struct {
+		in            string
+		width, height int
+		want          []string
+	}{
+		"short lines":   {"a\nb\nc\n", 10, 2, []string{"a\nb\n", "c\n"}},
+		"wrapped lines": {"abcdefgh\nx\n", 3, 2, []string{"abcdef", "gh\nx\n"}},
+		"exact width":   {"abc\nd\n", 3, 1, []string{"abc\n", "d\n"}},
+		"wide runes":    {"日本語\n", 5, 1, []string{"日本", "語\n"}},
+		"colors":        {"\x1b[31mred\nred\x1b[0m\n", 10, 1, []string{"\x1b[31mred\n\x1b[0m", "\x1b[31mred\x1b[0m\n"}},
+		"escaped break": {"\x1b]8;;a\nb\x07link\x1b]8;;\x07\nx\n", 10, 1, []string{"\x1b]8;;a\nb\x07link\x1b]8;;\x07\n", "x\n"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, page := range PaginateWidth([]byte(tc.in), tc.width, tc.height) {
+				got = append(got, string(page))
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
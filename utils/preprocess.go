@@ -0,0 +1,537 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PreprocessOptions configures PreprocessDynamicTextWithOptions. The zero
+// value gives the same results as PreprocessDynamicText.
+type PreprocessOptions struct {
+	// Truthy decides whether a variable's value enables an {{#if}} block.
+	// Defaults to DefaultTruthy.
+	Truthy func(string) bool
+
+	// Path is the file the content was read from. It enables file built-ins
+	// such as {{ last_modified }}; injected files set it to their own path.
+	Path string
+
+	// RestrictToBase rejects injected, included and sidecar files outside
+	// of BaseDir. BaseDir defaults to the directory of the top-level
+	// document.
+	RestrictToBase bool
+	BaseDir        string
+
+	// BreadcrumbRoot is the directory {{ breadcrumb }} starts from and
+	// BreadcrumbSeparator what it puts between the parts. They default to
+	// the directory of the top-level document and
+	// DefaultBreadcrumbSeparator.
+	BreadcrumbRoot      string
+	BreadcrumbSeparator string
+
+	// MetadataKeys and MetadataSeparator configure {{ meta_line }}. They
+	// default to DefaultMetadataKeys and DefaultMetadataSeparator.
+	MetadataKeys      []string
+	MetadataSeparator string
+
+	// TagsFooterLabel is what {{ tags_footer }} puts before the tags.
+	// Defaults to DefaultTagsFooterLabel.
+	TagsFooterLabel string
+
+	// TodoMarkers are the markers {{ todo_count }} counts. Nil means
+	// DefaultTodoMarkers.
+	TodoMarkers []string
+
+	// MaxPasses is how many times placeholders are substituted, so that
+	// variables can refer to other variables. Substitution stops early once
+	// nothing changes. Zero means a single pass.
+	MaxPasses int
+
+	// MaxIncludeDepth caps how deeply injected and included files may nest.
+	// Zero means DefaultMaxIncludeDepth.
+	MaxIncludeDepth int
+
+	// AllowRemote enables {{ include_url: ... }}, which fetches markdown over
+	// HTTP. It is off by default since documents could otherwise make
+	// arbitrary requests.
+	AllowRemote bool
+	// SafeMode disables remote includes even when AllowRemote is set.
+	SafeMode bool
+	// RemoteTimeout and MaxRemoteSize limit remote includes. Zero values
+	// mean DefaultRemoteTimeout and DefaultMaxRemoteSize.
+	RemoteTimeout time.Duration
+	MaxRemoteSize int64
+
+	// Rand picks the item {{ random: key }} shows. Set it to a seeded
+	// source for reproducible output; nil uses the global source.
+	Rand *rand.Rand
+
+	// includeStack holds the canonical paths of the files being processed,
+	// outermost first.
+	includeStack []string
+	// includeErr receives the first include error, if set.
+	includeErr *error
+	// remoteCache holds the remote includes fetched during this run.
+	remoteCache map[string]remoteInclude
+	// allBuiltins computes every built-in whether or not the document
+	// refers to it, which shows that computing them lazily changes nothing.
+	allBuiltins bool
+	// rawBlocks holds the {% raw %} blocks of the document and the files it
+	// pulls in. They are put back once the outermost call is done, so the
+	// directives of an including file can't expand them.
+	rawBlocks *[][]byte
+}
+
+// DefaultMaxIncludeDepth is the nesting limit for injected and included
+// files.
+const DefaultMaxIncludeDepth = 32
+
+// IncludeCycleError is returned by PreprocessWithIncludes when files include
+// each other.
+type IncludeCycleError struct {
+	// Chain lists the files from the outermost one to the one included
+	// again.
+	Chain []string
+}
+
+func (e *IncludeCycleError) Error() string {
+	return "include cycle: " + strings.Join(e.Chain, " -> ")
+}
+
+// maxIncludeDepth returns the configured include depth limit.
+func (o PreprocessOptions) maxIncludeDepth() int {
+	if o.MaxIncludeDepth > 0 {
+		return o.MaxIncludeDepth
+	}
+	return DefaultMaxIncludeDepth
+}
+
+// recordIncludeError keeps err if the caller asked for include errors and
+// none was recorded yet.
+func (o PreprocessOptions) recordIncludeError(err error) {
+	if o.includeErr != nil && *o.includeErr == nil {
+		*o.includeErr = err
+	}
+}
+
+// allowsPath reports whether the options permit reading the file at path.
+func (o PreprocessOptions) allowsPath(path string) bool {
+	if !o.RestrictToBase {
+		return true
+	}
+	return withinDir(o.BaseDir, path)
+}
+
+// allowsSidecar reports whether every sidecar file Path may have is allowed.
+func (o PreprocessOptions) allowsSidecar() bool {
+	for _, ext := range sidecarExtensions {
+		if !o.allowsPath(o.Path + ext) {
+			return false
+		}
+	}
+	return true
+}
+
+// withinDir reports whether path is dir itself or inside it, after resolving
+// symlinks where possible.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(canonicalPath(dir), canonicalPath(path))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// canonicalPath returns the absolute form of p with symlinks resolved where
+// possible.
+func canonicalPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	if real, err := filepath.EvalSymlinks(p); err == nil {
+		p = real
+	}
+	return p
+}
+
+// PreprocessFile reads the markdown file at path and preprocesses it with
+// the file built-ins available.
+func PreprocessFile(path string, opts PreprocessOptions) ([]byte, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get absolute path: %w", err)
+	}
+	content, err := readFileUTF8(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file: %w", err)
+	}
+
+	opts.Path = absPath
+	opts.includeStack = []string{canonicalPath(absPath)}
+	processedPaths := map[string]bool{absPath: true}
+	return PreprocessDynamicTextWithOptions(content, filepath.Dir(absPath), processedPaths, opts), nil
+}
+
+// PreprocessWithIncludes is like PreprocessFile but fails instead of leaving
+// an inline error when files include each other, returning an
+// *IncludeCycleError with the chain of files, or when includes nest deeper
+// than opts.MaxIncludeDepth.
+func PreprocessWithIncludes(path string, opts PreprocessOptions) ([]byte, error) {
+	var includeErr error
+	opts.includeErr = &includeErr
+
+	content, err := PreprocessFile(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	if includeErr != nil {
+		return nil, includeErr
+	}
+	return content, nil
+}
+
+// usedKeys is the set of variable names a document refers to.
+type usedKeys map[string]bool
+
+// placeholderWordPattern matches the names inside a placeholder.
+var placeholderWordPattern = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// findUsedKeys returns every name that appears inside a placeholder, {{#if}}
+// or {{#each}} of content or of the variables' values, which can end up in
+// the document. Unrelated words are included too, which is harmless.
+func findUsedKeys(content []byte, vars map[string]string) usedKeys {
+	used := make(usedKeys)
+	scan := func(b []byte) {
+		for _, p := range anyPlaceholderPattern.FindAll(b, -1) {
+			for _, w := range placeholderWordPattern.FindAll(p, -1) {
+				used[string(w)] = true
+			}
+		}
+	}
+	scan(content)
+	for _, v := range vars {
+		if strings.Contains(v, "{{") {
+			scan([]byte(v))
+		}
+	}
+	return used
+}
+
+func (u usedKeys) add(keys ...string) {
+	for _, k := range keys {
+		u[k] = true
+	}
+}
+
+func (u usedKeys) any(keys ...string) bool {
+	for _, k := range keys {
+		if u[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinKeys are the built-ins that are only computed when a document
+// refers to them.
+var builtinKeys = append([]string{
+	"pwd", "cwd", "pwd_short", "cwd_short", "user",
+	"breadcrumb", "last_modified", "last_modified_relative",
+	"excerpt", "word_count", "reading_time", "heading_count", "section_count",
+	"todo_count", "meta_line", "tags_footer", "cover_image",
+}, timeBuiltins...)
+
+// timeBuiltins are the built-ins set by addTimeVars.
+var timeBuiltins = []string{
+	"datetime_rfc3339", "datetime_rfc1123", "datetime", "datetime_iso",
+	"date_short", "date_long", "date_full", "custom_date", "date",
+	"time_12h", "time_24h", "time_long", "time", "tz_short", "tz_offset", "tz",
+	"timeofday", "timeofday_emoji",
+}
+
+// addTimeVars sets the date and time built-ins.
+func addTimeVars(vars map[string]string) {
+	now := time.Now()
+	hour := now.Hour()
+
+	vars["datetime_rfc3339"] = now.Format(time.RFC3339)
+	vars["datetime_rfc1123"] = now.Format(time.RFC1123)
+	vars["datetime"] = now.Format("2006-01-02 15:04")
+	vars["datetime_iso"] = now.Format("2006-01-02 15:04:05")
+	vars["date_short"] = now.Format("2006-01-02")
+	vars["date_long"] = now.Format("Jan 02, 2006")
+	vars["date_full"] = now.Format("Monday, 02 Jan 2006")
+	vars["custom_date"] = now.Format(vars["custom_date_fmt"]) // user custom_date_fmt var to format the date string
+	vars["date"] = vars["date_short"]
+
+	vars["time_12h"] = now.Format("03:04 PM")
+	vars["time_24h"] = now.Format("15:04")
+	vars["time_long"] = now.Format("15:04:05")
+	vars["time"] = vars["time_24h"]
+	vars["tz_short"] = now.Format("MST")
+	vars["tz_offset"] = now.Format("-7:00")
+	vars["tz"] = vars["tz_short"]
+
+	if hour >= 5 && hour < 12 {
+		vars["timeofday"] = "morning"
+		vars["timeofday_emoji"] = "☕"
+	} else if hour >= 12 && hour < 17 {
+		vars["timeofday"] = "afternoon"
+		vars["timeofday_emoji"] = "☀️"
+	} else {
+		vars["timeofday"] = "evening"
+		vars["timeofday_emoji"] = "🌙"
+	}
+}
+
+// addFileVars sets the built-ins that describe the file at opts.Path.
+func addFileVars(vars map[string]string, opts PreprocessOptions) {
+	vars["breadcrumb"] = Breadcrumb(opts.Path, opts.BreadcrumbRoot, opts.BreadcrumbSeparator)
+
+	info, err := os.Stat(opts.Path)
+	if err != nil {
+		return
+	}
+
+	vars["last_modified"] = info.ModTime().Format("2006-01-02 15:04")
+	vars["last_modified_relative"] = HumanizeDuration(time.Since(info.ModTime()))
+}
+
+// DefaultTagsFooterLabel is the label of {{ tags_footer }}.
+const DefaultTagsFooterLabel = "Tags: "
+
+// tagsFooter returns the {{ tags_footer }} line for the comma-separated
+// tags, set in italics so it renders subdued, or the empty string if there
+// are no tags.
+func tagsFooter(tags, label string) string {
+	if strings.TrimSpace(tags) == "" {
+		return ""
+	}
+	if label == "" {
+		label = DefaultTagsFooterLabel
+	}
+	return "*" + EscapeMarkdown(label+tags) + "*"
+}
+
+func (o PreprocessOptions) truthy() func(string) bool {
+	if o.Truthy != nil {
+		return o.Truthy
+	}
+	return DefaultTruthy
+}
+
+// DefaultTruthy reports whether a variable's value counts as true. Values
+// are compared case-insensitively after trimming spaces: the empty string,
+// "false", "no", "off" and "0" are false, everything else is true.
+func DefaultTruthy(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "false", "no", "off", "0":
+		return false
+	default:
+		return true
+	}
+}
+
+var (
+	conditionalPattern = regexp.MustCompile(`(?s)\{\{\s*#if\s+([^{}\s]+)\s*\}\}(.*?)\{\{\s*/if\s*\}\}`)
+	elsePattern        = regexp.MustCompile(`\{\{\s*#else\s*\}\}`)
+	loopPattern        = regexp.MustCompile(`(?s)\{\{\s*#each\s+([^{}\s]+)\s*\}\}(.*?)\{\{\s*/each\s*\}\}`)
+	loopItemPattern    = regexp.MustCompile(`\{\{\s*\.\s*\}\}`)
+
+	// comments never span lines, so a stray "{{!" can't swallow the document
+	commentPattern = regexp.MustCompile(`\{\{![^\n]*?\}\}`)
+
+	rawBlockPattern       = regexp.MustCompile(`(?s)\{%-?\s*raw\s*-?%\}(.*?)\{%-?\s*endraw\s*-?%\}`)
+	rawPlaceholderPattern = regexp.MustCompile("\x00raw:(\\d+)\x00")
+)
+
+// protectRaw swaps the {% raw %}...{% endraw %} blocks in content for
+// placeholders that no substitution touches, appending their contents to
+// blocks. Markers without a partner are left alone.
+func protectRaw(content []byte, blocks *[][]byte) []byte {
+	return rawBlockPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		*blocks = append(*blocks, rawBlockPattern.FindSubmatch(match)[1])
+		return []byte("\x00raw:" + strconv.Itoa(len(*blocks)-1) + "\x00")
+	})
+}
+
+// restoreRaw puts the contents of the raw blocks protectRaw took out of b
+// back, without the markers.
+func restoreRaw(b []byte, blocks [][]byte) []byte {
+	return rawPlaceholderPattern.ReplaceAllFunc(b, func(match []byte) []byte {
+		n, err := strconv.Atoi(string(rawPlaceholderPattern.FindSubmatch(match)[1]))
+		if err != nil || n >= len(blocks) {
+			return match
+		}
+		return blocks[n]
+	})
+}
+
+// expandConditionals resolves every {{#if key}}...{{#else}}...{{/if}} block
+// to its first branch when the variable is truthy and to the optional else
+// branch otherwise. Unknown variables are false. Blocks can't be nested, and
+// only the first {{#else}} of a block splits it.
+func expandConditionals(content []byte, vars map[string]string, truthy func(string) bool) []byte {
+	return conditionalPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		m := conditionalPattern.FindSubmatch(match)
+		then, otherwise := m[2], []byte(nil)
+		if loc := elsePattern.FindIndex(then); loc != nil {
+			then, otherwise = m[2][:loc[0]], m[2][loc[1]:]
+		}
+
+		if v, ok := vars[string(m[1])]; ok && truthy(v) {
+			return then
+		}
+		return otherwise
+	})
+}
+
+// expandLoops repeats the body of every {{#each key}}...{{/each}} block once
+// per item of the list-valued frontmatter key, replacing {{ . }} with the
+// item. Blocks for missing or non-list keys are dropped. Loops can't be
+// nested.
+func expandLoops(content []byte, raw map[string]interface{}) []byte {
+	return loopPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		m := loopPattern.FindSubmatch(match)
+		items, ok := lookupFrontmatter(raw, string(m[1])).([]interface{})
+		if !ok {
+			return nil
+		}
+
+		var out []byte
+		for _, item := range items {
+			value := []byte(scalarToString(item))
+			out = append(out, loopItemPattern.ReplaceAllLiteral(m[2], value)...)
+		}
+		return out
+	})
+}
+
+// lookupFrontmatter returns the raw frontmatter value at a dotted key path,
+// or nil if there is none.
+func lookupFrontmatter(raw map[string]interface{}, key string) interface{} {
+	return lookupFrontmatterPath(raw, strings.Split(key, "."))
+}
+
+// lookupFrontmatterPath returns the raw frontmatter value found by following
+// path through nested maps and lists, or nil if there is none.
+func lookupFrontmatterPath(raw map[string]interface{}, path []string) interface{} {
+	var cur interface{} = raw
+	for _, part := range path {
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			cur = v[part]
+		case []interface{}:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			cur = v[i]
+		default:
+			return nil
+		}
+	}
+	return cur
+}
+
+// parseKeyPath splits a placeholder key such as a["weird key"].b or tags[0]
+// into its parts. Bracketed parts may be quoted with ' or ".
+func parseKeyPath(key string) ([]string, bool) {
+	var path []string
+	for key != "" {
+		switch key[0] {
+		case '.':
+			key = key[1:]
+		case '[':
+			end := strings.IndexByte(key, ']')
+			if end < 0 {
+				return nil, false
+			}
+			part := key[1:end]
+			if len(part) >= 2 && (part[0] == '"' || part[0] == '\'') && part[len(part)-1] == part[0] {
+				part = part[1 : len(part)-1]
+			}
+			path = append(path, part)
+			key = key[end+1:]
+		default:
+			end := strings.IndexAny(key, ".[")
+			if end < 0 {
+				end = len(key)
+			}
+			path = append(path, key[:end])
+			key = key[end:]
+		}
+	}
+	return path, len(path) > 0
+}
+
+// frontmatterResolver returns a placeholder lookup over the flattened vars.
+// It falls back to bracketed key paths into the raw frontmatter, for keys
+// containing dots or spaces. <key>_count resolves to the length of the list
+// at key unless a variable of that name exists. {{ random: tips }} picks an
+// item of a list and {{ now: "Mon Jan 2 15:04" }} formats the current time
+// with a quoted Go layout.
+func frontmatterResolver(vars map[string]string, raw map[string]interface{}, rng *rand.Rand) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		if v, ok := vars[key]; ok {
+			return v, true
+		}
+		if list, ok := strings.CutSuffix(key, "_count"); ok {
+			if path, ok := parseKeyPath(list); ok {
+				if items, ok := lookupFrontmatterPath(raw, path).([]interface{}); ok {
+					return strconv.Itoa(len(items)), true
+				}
+			}
+		}
+		if layout, ok := strings.CutPrefix(key, "now:"); ok {
+			layout = strings.TrimSpace(layout)
+			if n := len(layout); n >= 2 && (layout[0] == '"' || layout[0] == '\'') && layout[n-1] == layout[0] {
+				layout = layout[1 : n-1]
+			}
+			if layout == "" {
+				return "", false
+			}
+			return time.Now().Format(layout), true
+		}
+		var v interface{}
+		if list, ok := strings.CutPrefix(key, "random:"); ok {
+			path, ok := parseKeyPath(strings.TrimSpace(list))
+			if !ok {
+				return "", false
+			}
+			items, ok := lookupFrontmatterPath(raw, path).([]interface{})
+			if !ok || len(items) == 0 {
+				return "", false
+			}
+			if rng != nil {
+				v = items[rng.IntN(len(items))]
+			} else {
+				v = items[rand.IntN(len(items))]
+			}
+		} else {
+			if !strings.Contains(key, "[") {
+				return "", false
+			}
+			path, ok := parseKeyPath(key)
+			if !ok {
+				return "", false
+			}
+			v = lookupFrontmatterPath(raw, path)
+			if v == nil {
+				return "", false
+			}
+		}
+		flat := make(map[string]string)
+		flattenYAML("", v, flat)
+		if s, ok := flat[""]; ok && len(flat) == 1 {
+			return s, true
+		}
+		return scalarToString(v), true
+	}
+}
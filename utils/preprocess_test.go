@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandConditionals(t *testing.T) {
+	vars := map[string]string{
+		"draft":     "true",
+		"published": "no",
+	}
+
+	for in, want := range map[string]string{
+		"{{#if draft}}draft{{/if}}":                             "draft",
+		"{{#if published}}published{{/if}}":                     "",
+		"{{#if missing}}missing{{/if}}":                         "",
+		"{{#if draft}}draft{{#else}}final{{/if}}":               "draft",
+		"{{#if published}}live{{#else}}hidden{{/if}}":           "hidden",
+		"{{ #if published }}a{{ #else }}b{{ #else }}c{{ /if }}": "b{{ #else }}c",
+		"{{#if draft}}\nmulti\nline\n{{/if}}":                   "\nmulti\nline\n",
+	} {
+		t.Run(in, func(t *testing.T) {
+			if got := string(expandConditionals([]byte(in), vars, DefaultTruthy)); got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+func TestCustomTruthy(t *testing.T) {
+	vars := map[string]string{"flag": "no"}
+	truthy := func(s string) bool { return strings.TrimSpace(s) != "" }
+
+	got := string(expandConditionals([]byte("{{#if flag}}on{{#else}}off{{/if}}"), vars, truthy))
+	if got != "on" {
+		t.Errorf("expected custom truthy to accept %q, got %q", vars["flag"], got)
+	}
+}
+
+func TestRestrictToBase(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base")
+	if err := os.MkdirAll(base, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.md"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "doc.md"), []byte("doc"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	in := []byte("{{ include: ../secret.md }} {{inject[doc.md]}}")
+	got := string(PreprocessDynamicTextWithOptions(in, base, map[string]bool{}, PreprocessOptions{RestrictToBase: true}))
+	if !strings.HasPrefix(got, "{{include_error: ../secret.md is outside of") {
+		t.Errorf("expected the include outside of the base directory to be blocked, got %q", got)
+	}
+	if !strings.Contains(got, "doc") {
+		t.Errorf("expected the inject inside the base directory to work, got %q", got)
+	}
+}
+
+func TestPreprocessWithIncludesCycle(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.md"), []byte("{{inject[b.md]}}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.md"), []byte("{{ include: a.md }}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := PreprocessWithIncludes(filepath.Join(dir, "a.md"), PreprocessOptions{})
+	var cycle *IncludeCycleError
+	if !errors.As(err, &cycle) {
+		t.Fatalf("expected an include cycle error, got %v", err)
+	}
+	if len(cycle.Chain) != 3 || filepath.Base(cycle.Chain[0]) != "a.md" || filepath.Base(cycle.Chain[1]) != "b.md" || filepath.Base(cycle.Chain[2]) != "a.md" {
+		t.Errorf("expected the chain a.md -> b.md -> a.md, got %v", cycle.Chain)
+	}
+}
+
+func TestIncludeGlobSkipsSelf(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"index.md": "{{ include: *.md }}",
+		"a.md":     "alpha",
+		"b.md":     "beta",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := PreprocessWithIncludes(filepath.Join(dir, "index.md"), PreprocessOptions{})
+	if err != nil {
+		t.Fatalf("expected no include error, got %v", err)
+	}
+	if string(got) != "alpha\n\nbeta" {
+		t.Errorf("expected %q, got %q", "alpha\n\nbeta", got)
+	}
+}
+
+func TestTodoCount(t *testing.T) {
+	in := []byte("{{ todo_count }}\n\nTODO: write\nFIXME: broken\nNOTE: an aside\n")
+	if got := string(PreprocessDynamicTextWithOptions(in, ".", map[string]bool{}, PreprocessOptions{})); !strings.HasPrefix(got, "2\n") {
+		t.Errorf("expected notes not to be counted, got %q", got)
+	}
+	opts := PreprocessOptions{TodoMarkers: DefaultMarkers}
+	if got := string(PreprocessDynamicTextWithOptions(in, ".", map[string]bool{}, opts)); !strings.HasPrefix(got, "3\n") {
+		t.Errorf("expected the configured markers to be counted, got %q", got)
+	}
+}
+
+func TestMetaLineWithoutDate(t *testing.T) {
+	in := []byte("---\nauthor: Jane\n---\n{{ meta_line }}\n\nSome words to read.\n")
+	want := MetadataLine(in, DefaultMetadataKeys)
+	if want != "Jane · 1 min read" {
+		t.Fatalf("expected %q, got %q", "Jane · 1 min read", want)
+	}
+	got, _, _ := strings.Cut(string(PreprocessDynamicTextWithOptions(in, ".", map[string]bool{}, PreprocessOptions{})), "\n")
+	if got != want {
+		t.Errorf("expected {{ meta_line }} to match MetadataLine %q, got %q", want, got)
+	}
+}
+
+func TestLazyBuiltinsMatchEager(t *testing.T) {
+	docs := map[string][]byte{
+		"builtins": []byte("---\nauthor: Jane\ntags: [a, b]\nsummary: \"{{ word_count }} words\"\n---\n" +
+			"# Title\n\n{{ meta_line }} {{ summary }} {{ heading_count }} {{ section_count }} {{ todo_count }}\n\n" +
+			"{{ excerpt }} {{ excerpt_5 }} {{ reading_time }} {{ tags_footer }} {{ cover_image }}\n\n" +
+			"TODO: more ![logo](logo.png)\n"),
+		"unused": []byte("---\nauthor: Jane\n---\nNo built-ins here.\n"),
+	}
+	files, err := filepath.Glob("testdata/*.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs[name] = b
+	}
+
+	for name, in := range docs {
+		t.Run(name, func(t *testing.T) {
+			lazy := PreprocessDynamicTextWithOptions(in, ".", map[string]bool{}, PreprocessOptions{MaxPasses: 2})
+			eager := PreprocessDynamicTextWithOptions(in, ".", map[string]bool{}, PreprocessOptions{MaxPasses: 2, allBuiltins: true})
+			if string(lazy) != string(eager) {
+				t.Errorf("expected %q, got %q", eager, lazy)
+			}
+		})
+	}
+}
+
+func TestIncludesConvertedToUTF8(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string][]byte{
+		"doc.md":      []byte("{{inject[latin.md]}} {{ include: utf16.md }} {{ title }}"),
+		"doc.md.yaml": []byte("title: na\xefve\n"),
+		"latin.md":    []byte("caf\xe9"),
+		"utf16.md":    {0xff, 0xfe, 'h', 0, 'i', 0},
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := PreprocessFile(filepath.Join(dir, "doc.md"), PreprocessOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "café hi naïve" {
+		t.Errorf("expected %q, got %q", "café hi naïve", got)
+	}
+}
+
+func TestIncludeURL(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		_, _ = w.Write([]byte("---\ntitle: Remote\n---\nshared"))
+	}))
+	defer srv.Close()
+
+	in := []byte("{{ include_url: " + srv.URL + " }} {{ include_url: " + srv.URL + " }}")
+	if got := string(PreprocessDynamicTextWithOptions(in, ".", map[string]bool{}, PreprocessOptions{})); !strings.Contains(got, "include_url_error: remote includes are disabled") {
+		t.Errorf("expected remote includes to be disabled by default, got %q", got)
+	}
+	if got := string(PreprocessDynamicTextWithOptions(in, ".", map[string]bool{}, PreprocessOptions{AllowRemote: true, SafeMode: true})); !strings.Contains(got, "include_url_error") {
+		t.Errorf("expected safe mode to disable remote includes, got %q", got)
+	}
+
+	got := string(PreprocessDynamicTextWithOptions(in, ".", map[string]bool{}, PreprocessOptions{AllowRemote: true}))
+	if got != "shared shared" {
+		t.Errorf("expected %q, got %q", "shared shared", got)
+	}
+	if requests != 1 {
+		t.Errorf("expected the response to be cached, got %d requests", requests)
+	}
+}
+
+func TestRandomPlaceholder(t *testing.T) {
+	content := []byte("---\ntips:\n  - one\n  - two\n  - three\ntitle: Tips\n---\n{{ random: tips }} {{ random: title }}")
+
+	seen := make(map[string]bool)
+	for seed := range uint64(20) {
+		opts := PreprocessOptions{Rand: rand.New(rand.NewPCG(seed, seed))}
+		tip, rest, _ := strings.Cut(string(PreprocessDynamicTextWithOptions(content, ".", map[string]bool{}, opts)), " ")
+		if rest != "{{ random: title }}" {
+			t.Errorf("expected a scalar to stay unresolved, got %q", rest)
+		}
+		seen[tip] = true
+	}
+	for _, tip := range []string{"one", "two", "three"} {
+		if !seen[tip] {
+			t.Errorf("expected %q to be picked at least once, got %v", tip, seen)
+		}
+	}
+}
+
+func TestRawBlocks(t *testing.T) {
+	in := []byte("---\nname: glow\n---\n{{ name }} {% raw %}{{ name }} {{! kept }}{% endraw %} {% raw %}{{ name }}")
+	want := "glow {{ name }} {{! kept }} {% raw %}glow"
+	if got := string(PreprocessDynamicTextWithOptions(in, ".", map[string]bool{}, PreprocessOptions{})); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRawBlocksInIncludedFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"inc.md":   "{% raw %}{{ include: other.md }} {{ include_code: x.py }}{% endraw %}",
+		"other.md": "OTHER",
+		"x.py":     "print(1)\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := "{{ include: other.md }} {{ include_code: x.py }}"
+	for _, in := range []string{"{{inject[inc.md]}}", "{{ include: inc.md }}"} {
+		if got := string(PreprocessDynamicTextWithOptions([]byte(in), dir, map[string]bool{}, PreprocessOptions{})); got != want {
+			t.Errorf("%s: expected %q, got %q", in, want, got)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+import "regexp"
+
+// RedactMask replaces the secrets Redact finds.
+const RedactMask = "****"
+
+// DefaultRedactPatterns are the secrets Redact masks when no patterns are
+// given: AWS access keys and secret keys, bearer tokens and GitHub tokens.
+// Append to the slice to mask more.
+var DefaultRedactPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`(?i)aws_secret_access_key\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`),
+	regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]+=*)`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+}
+
+// Redact replaces the matches of patterns in content with RedactMask. When
+// a pattern has a capture group only the first group is masked, so context
+// such as "Bearer " stays readable. Nil patterns means
+// DefaultRedactPatterns. Run it on the output of PreprocessDynamicText so
+// that substituted values are masked too.
+func Redact(content []byte, patterns []*regexp.Regexp) []byte {
+	if patterns == nil {
+		patterns = DefaultRedactPatterns
+	}
+	for _, p := range patterns {
+		content = p.ReplaceAllFunc(content, func(match []byte) []byte {
+			loc := p.FindSubmatchIndex(match)
+			if len(loc) < 4 || loc[2] < 0 {
+				return []byte(RedactMask)
+			}
+			out := append([]byte{}, match[:loc[2]]...)
+			out = append(out, RedactMask...)
+			return append(out, match[loc[3]:]...)
+		})
+	}
+	return content
+}
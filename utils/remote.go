@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Limits for {{ include_url: ... }}.
+const (
+	DefaultRemoteTimeout = 10 * time.Second
+	DefaultMaxRemoteSize = 1 << 20
+)
+
+// remoteInclude is the outcome of fetching a remote include.
+type remoteInclude struct {
+	content []byte
+	err     error
+}
+
+// includeURL returns the markdown at rawURL with its frontmatter stripped,
+// or an inline error. Remote content isn't preprocessed, so it can't pull in
+// local files.
+func includeURL(rawURL string, opts PreprocessOptions) []byte {
+	if !opts.AllowRemote || opts.SafeMode {
+		return []byte("{{include_url_error: remote includes are disabled}}")
+	}
+
+	r, ok := opts.remoteCache[rawURL]
+	if !ok {
+		r.content, r.err = fetchRemote(rawURL, opts)
+		opts.remoteCache[rawURL] = r
+	}
+	if r.err != nil {
+		return []byte(fmt.Sprintf("{{include_url_error: %s}}", r.err))
+	}
+	return RemoveFrontmatter(r.content)
+}
+
+// fetchRemote downloads rawURL within the timeout and size limits of opts.
+func fetchRemote(rawURL string, opts PreprocessOptions) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("%s is not an http(s) URL", rawURL)
+	}
+
+	timeout := opts.RemoteTimeout
+	if timeout <= 0 {
+		timeout = DefaultRemoteTimeout
+	}
+	limit := opts.MaxRemoteSize
+	if limit <= 0 {
+		limit = DefaultMaxRemoteSize
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch %s: HTTP status %d", rawURL, resp.StatusCode)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", rawURL, err)
+	}
+	if int64(len(b)) > limit {
+		return nil, fmt.Errorf("%s is larger than %d bytes", rawURL, limit)
+	}
+	return b, nil
+}
@@ -0,0 +1,38 @@
+package utils
+
+import "regexp"
+
+var hugoShortcodePattern = regexp.MustCompile(`(?s)\{\{([<%])\s*(/?)\s*([^\s>%]*).*?([>%])\}\}`)
+
+// StripHugoShortcodes removes Hugo {{< ... >}} and {{% ... %}} shortcodes
+// from content. Fenced code is left alone so shortcodes can still be
+// documented.
+func StripHugoShortcodes(content []byte) []byte {
+	return replaceHugoShortcodes(content, func(_ string, _ bool) []byte {
+		return nil
+	})
+}
+
+// NoteHugoShortcodes is like StripHugoShortcodes but leaves a short note
+// such as *[figure]* where every opening shortcode was.
+func NoteHugoShortcodes(content []byte) []byte {
+	return replaceHugoShortcodes(content, func(name string, closing bool) []byte {
+		if closing || name == "" {
+			return nil
+		}
+		return []byte("*[" + name + "]*")
+	})
+}
+
+func replaceHugoShortcodes(content []byte, fn func(name string, closing bool) []byte) []byte {
+	return mapOutsideFences(content, func(b []byte) []byte {
+		return hugoShortcodePattern.ReplaceAllFunc(b, func(match []byte) []byte {
+			m := hugoShortcodePattern.FindSubmatch(match)
+			// the delimiters must pair up: {{< >}} or {{% %}}
+			if (m[1][0] == '<') != (m[4][0] == '>') {
+				return match
+			}
+			return fn(string(m[3]), len(m[2]) > 0)
+		})
+	})
+}
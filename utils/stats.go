@@ -0,0 +1,37 @@
+package utils
+
+// DocStats summarizes a markdown document.
+type DocStats struct {
+	Words          int
+	ReadingTime    int
+	Headings       int
+	CodeBlocks     int
+	Links          int
+	Images         int
+	HasFrontmatter bool
+}
+
+// Stats returns the statistics of content. Words and ReadingTime count the
+// prose the same way WordCount and ReadingTime do, leaving out the
+// frontmatter and code; links and images in code are not counted either.
+// Each figure comes from the counter that computes it elsewhere, so they
+// always agree, at the cost of scanning the document once per counter.
+func Stats(content []byte) DocStats {
+	body := RemoveFrontmatter(content)
+	words := WordCount(body)
+	stats := DocStats{
+		Words:          words,
+		ReadingTime:    readingMinutes(words),
+		Headings:       len(findHeadings(body)),
+		CodeBlocks:     len(findFences(body)),
+		HasFrontmatter: len(body) < len(content),
+	}
+	for _, l := range findLinks(body) {
+		if l.Image {
+			stats.Images++
+		} else {
+			stats.Links++
+		}
+	}
+	return stats
+}
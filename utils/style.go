@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/muesli/termenv"
+	"golang.org/x/term"
+)
+
+// GlamourStyleFromReader returns a glamour.TermRendererOption based on the
+// JSON style config read from r.
+func GlamourStyleFromReader(r io.Reader, isCode bool) (glamour.TermRendererOption, error) {
+	var styleConfig ansi.StyleConfig
+	if err := json.NewDecoder(r).Decode(&styleConfig); err != nil {
+		return nil, fmt.Errorf("unable to decode style: %w", err)
+	}
+
+	if isCode {
+		stripCodeBlockMargin(&styleConfig)
+	}
+	return glamour.WithStyles(styleConfig), nil
+}
+
+// stripCodeBlockMargin removes the code block margin so pure code renders
+// flush with the terminal edge.
+func stripCodeBlockMargin(styleConfig *ansi.StyleConfig) {
+	var margin uint
+	styleConfig.CodeBlock.Margin = &margin
+}
+
+// MergeStyleConfig returns base with every field set in override applied on
+// top of it. Nested blocks are merged field by field, so an override only
+// needs to contain the settings it changes.
+func MergeStyleConfig(base, override ansi.StyleConfig) ansi.StyleConfig {
+	merged := base
+	mergeStyleValue(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(override))
+	return merged
+}
+
+// mergeStyleValue copies the non-zero parts of src onto dst.
+func mergeStyleValue(dst, src reflect.Value) {
+	switch src.Kind() { //nolint:exhaustive
+	case reflect.Struct:
+		for i := range src.NumField() {
+			mergeStyleValue(dst.Field(i), src.Field(i))
+		}
+	case reflect.Pointer:
+		if src.IsNil() {
+			return
+		}
+		if dst.IsNil() || src.Elem().Kind() != reflect.Struct {
+			dst.Set(src)
+			return
+		}
+		// copy before merging so the base config's struct isn't modified
+		elem := reflect.New(dst.Elem().Type())
+		elem.Elem().Set(dst.Elem())
+		mergeStyleValue(elem.Elem(), src.Elem())
+		dst.Set(elem)
+	default:
+		if !src.IsZero() {
+			dst.Set(src)
+		}
+	}
+}
+
+// GlamourStyleWithOverrides returns a glamour.TermRendererOption for the
+// given built-in style with the partial JSON style config read from r merged
+// on top of it.
+func GlamourStyleWithOverrides(style string, r io.Reader, isCode bool) (glamour.TermRendererOption, error) {
+	base, ok := builtinStyleConfig(style)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a built-in style", style)
+	}
+
+	var override ansi.StyleConfig
+	if err := json.NewDecoder(r).Decode(&override); err != nil {
+		return nil, fmt.Errorf("unable to decode style: %w", err)
+	}
+
+	styleConfig := MergeStyleConfig(base, override)
+	if isCode {
+		stripCodeBlockMargin(&styleConfig)
+	}
+	return glamour.WithStyles(styleConfig), nil
+}
+
+var (
+	registeredStylesMu sync.RWMutex
+	registeredStyles   = make(map[string]ansi.StyleConfig)
+)
+
+// RegisterStyle makes cfg available under name wherever a style name is
+// accepted, such as GlamourStyle. Registered styles take precedence over
+// built-in styles and style file paths of the same name, and registering a
+// name again replaces its config.
+func RegisterStyle(name string, cfg ansi.StyleConfig) {
+	registeredStylesMu.Lock()
+	defer registeredStylesMu.Unlock()
+	registeredStyles[name] = cfg
+}
+
+// registeredStyle returns the config registered under name.
+func registeredStyle(name string) (ansi.StyleConfig, bool) {
+	registeredStylesMu.RLock()
+	defer registeredStylesMu.RUnlock()
+	cfg, ok := registeredStyles[name]
+	return cfg, ok
+}
+
+// BuiltinStyles returns the sorted names of the built-in and registered
+// styles.
+func BuiltinStyles() []string {
+	names := []string{styles.AutoStyle, AdaptiveStyle}
+	for name := range styles.DefaultStyles {
+		names = append(names, name)
+	}
+	registeredStylesMu.RLock()
+	for name := range registeredStyles {
+		names = append(names, name)
+	}
+	registeredStylesMu.RUnlock()
+
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// DetectBackground queries the terminal for its background color and reports
+// whether it is dark. Unlike lipgloss.HasDarkBackground, the result is not
+// cached, so long-running programs can call it again to pick up a theme
+// change.
+func DetectBackground() (isDark bool) {
+	return termenv.NewOutput(os.Stdout).HasDarkBackground()
+}
+
+// AdaptiveStyle is a style name that, unlike styles.AutoStyle, chooses
+// among more than two styles based on the measured background luminance.
+const AdaptiveStyle = "adaptive"
+
+// Background luminance thresholds used by StyleForBackground.
+const (
+	veryDarkLuminance = 0.05
+	lightLuminance    = 0.4
+)
+
+// StyleForBackground returns the built-in style with the best contrast on a
+// background of the given relative luminance, from 0 for black to 1 for
+// white: the dark style for very dark backgrounds, the more saturated
+// Dracula style for dark and medium gray ones and the light style otherwise.
+func StyleForBackground(luminance float64) ansi.StyleConfig {
+	switch {
+	case luminance < veryDarkLuminance:
+		return styles.DarkStyleConfig
+	case luminance < lightLuminance:
+		return styles.DraculaStyleConfig
+	default:
+		return styles.LightStyleConfig
+	}
+}
+
+// BackgroundLuminance queries the terminal for its background color and
+// returns its relative luminance. ok is false when stdout isn't a terminal.
+func BackgroundLuminance() (luminance float64, ok bool) {
+	if !term.IsTerminal(int(os.Stdout.Fd())) { //nolint:gosec
+		return 0, false
+	}
+	c := termenv.ConvertToRGB(termenv.NewOutput(os.Stdout).BackgroundColor())
+
+	linear := func(v float64) float64 {
+		if v <= 0.04045 {
+			return v / 12.92
+		}
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return 0.2126*linear(c.R) + 0.7152*linear(c.G) + 0.0722*linear(c.B), true
+}
+
+// RefreshAutoStyle returns a glamour.TermRendererOption for the auto style
+// based on a fresh background detection. GlamourStyle keeps using the
+// background detected at startup.
+func RefreshAutoStyle(isCode bool) glamour.TermRendererOption {
+	styleConfig := styles.LightStyleConfig
+	if DetectBackground() {
+		styleConfig = styles.DarkStyleConfig
+	}
+
+	if isCode {
+		stripCodeBlockMargin(&styleConfig)
+	}
+	return glamour.WithStyles(styleConfig)
+}
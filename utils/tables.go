@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// table is a GFM table found in a document.
+type table struct {
+	start, end int // byte offsets of the table's first and after its last line
+	header     []string
+	align      []string // the raw separator cells, e.g. ":---"
+	rows       [][]string
+}
+
+var tableSeparatorPattern = regexp.MustCompile(`^[ \t]*\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+
+// findTables returns the tables in content. Callers are expected to skip
+// fenced code themselves.
+func findTables(content []byte) []table {
+	var tables []table
+	lines := splitLines(content)
+	offsets := make([]int, len(lines)+1)
+	for i, line := range lines {
+		offsets[i+1] = offsets[i] + len(line)
+	}
+
+	for i := 0; i+1 < len(lines); i++ {
+		head, sep := string(lines[i]), strings.TrimRight(string(lines[i+1]), "\r\n")
+		if !strings.Contains(head, "|") || !strings.Contains(sep, "|") || !tableSeparatorPattern.MatchString(sep) {
+			continue
+		}
+		t := table{
+			start:  offsets[i],
+			header: splitTableRow(head),
+			align:  splitTableRow(sep),
+		}
+		if len(t.header) != len(t.align) {
+			continue
+		}
+
+		j := i + 2
+		for ; j < len(lines); j++ {
+			row := string(lines[j])
+			if strings.TrimSpace(row) == "" || !strings.Contains(row, "|") {
+				break
+			}
+			t.rows = append(t.rows, splitTableRow(row))
+		}
+		t.end = offsets[j]
+		tables = append(tables, t)
+		i = j - 1
+	}
+	return tables
+}
+
+// splitTableRow splits a table line into its trimmed cells. Escaped pipes
+// and pipes inside code spans don't split cells.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cur strings.Builder
+	inCode := false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cur.WriteString(`\|`)
+			i++
+		case c == '`':
+			inCode = !inCode
+			cur.WriteByte(c)
+		case c == '|' && !inCode:
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+// mapTables replaces every table outside of fenced code with the result of
+// fn.
+func mapTables(content []byte, fn func(t table, src []byte) []byte) []byte {
+	return mapOutsideFences(content, func(b []byte) []byte {
+		var out bytes.Buffer
+		last := 0
+		for _, t := range findTables(b) {
+			out.Write(b[last:t.start])
+			out.Write(fn(t, b[t.start:t.end]))
+			last = t.end
+		}
+		out.Write(b[last:])
+		return out.Bytes()
+	})
+}
+
+// columnWidths returns the widest cell of every column.
+func (t table) columnWidths() []int {
+	widths := make([]int, len(t.header))
+	for _, row := range append([][]string{t.header}, t.rows...) {
+		for i, cell := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], VisibleWidth(cell))
+			}
+		}
+	}
+	return widths
+}
+
+// width returns the number of columns the table needs when every cell is
+// padded to its column's width.
+func (t table) width() int {
+	w := 1
+	for _, cw := range t.columnWidths() {
+		w += cw + 3
+	}
+	return w
+}
+
+// WrapTables converts tables that are wider than width into a list of
+// key/value records, which reads better in narrow terminals. Tables that fit
+// are left untouched.
+func WrapTables(content []byte, width int) []byte {
+	return mapTables(content, func(t table, src []byte) []byte {
+		if t.width() <= width || len(t.rows) == 0 {
+			return src
+		}
+
+		var b strings.Builder
+		for i, row := range t.rows {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			for j, h := range t.header {
+				var value string
+				if j < len(row) {
+					value = row[j]
+				}
+				if h != "" {
+					b.WriteString("**" + h + ":** ")
+				}
+				b.WriteString(value)
+				if j < len(t.header)-1 {
+					b.WriteString("  ")
+				}
+				b.WriteString("\n")
+			}
+		}
+		return []byte(b.String())
+	})
+}
+
+var definitionPattern = regexp.MustCompile(`^ {0,3}:[ \t]+(.*?)\s*$`)
+
+// DefinitionListsToTables converts PHP Markdown Extra definition lists,
+//
+//	Term
+//	: Definition
+//
+// into two-column tables, which glamour renders cleanly. A term with several
+// definitions gets one row per definition. Everything else is left as-is.
+func DefinitionListsToTables(content []byte) []byte {
+	return mapOutsideFences(content, func(b []byte) []byte {
+		lines := splitLines(b)
+		blank := func(i int) bool { return len(bytes.TrimSpace(lines[i])) == 0 }
+
+		// definitionStart returns the index of the first definition of the
+		// term on line i, allowing one blank line in between, or -1.
+		definitionStart := func(i int) int {
+			if i >= len(lines) || blank(i) || definitionPattern.Match(lines[i]) || notTermPattern.Match(lines[i]) {
+				return -1
+			}
+			j := i + 1
+			if j < len(lines) && blank(j) {
+				j++
+			}
+			if j < len(lines) && definitionPattern.Match(lines[j]) {
+				return j
+			}
+			return -1
+		}
+
+		var out bytes.Buffer
+		for i := 0; i < len(lines); i++ {
+			j := definitionStart(i)
+			if j < 0 || (i > 0 && !blank(i-1)) {
+				out.Write(lines[i])
+				continue
+			}
+
+			out.WriteString("| Term | Definition |\n| --- | --- |\n")
+			for j >= 0 {
+				term := tableCell(string(lines[i]))
+				for ; j < len(lines); j++ {
+					m := definitionPattern.FindSubmatch(lines[j])
+					if m == nil {
+						break
+					}
+					out.WriteString("| " + term + " | " + tableCell(string(m[1])) + " |\n")
+					term = ""
+				}
+
+				// another term may follow after blank lines
+				end, next := j, j
+				for next < len(lines) && blank(next) {
+					next++
+				}
+				if j = definitionStart(next); j >= 0 {
+					i = next
+				} else {
+					i = next - 1
+					if next > end {
+						out.WriteString("\n")
+					}
+				}
+			}
+		}
+		return out.Bytes()
+	})
+}
+
+// tableCell trims s and escapes the pipes in it for use as a table cell.
+func tableCell(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), "|", `\|`)
+}
+
+// NormalizeTables rewrites tables in a consistent style: every row starts
+// and ends with a pipe, cells are padded to their column's width according
+// to its alignment, and separator rows use dashes of the same width while
+// keeping their alignment colons.
+func NormalizeTables(content []byte) []byte {
+	return mapTables(content, func(t table, src []byte) []byte {
+		widths := t.columnWidths()
+		for i := range widths {
+			widths[i] = max(widths[i], 3)
+		}
+
+		var b strings.Builder
+		writeRow := func(cells []string) {
+			b.WriteString("|")
+			for i, cell := range cells {
+				if i < len(widths) {
+					cell = padCell(cell, widths[i], t.align[i])
+				}
+				b.WriteString(" " + cell + " |")
+			}
+			b.WriteString("\n")
+		}
+
+		writeRow(t.header)
+		b.WriteString("|")
+		for i, a := range t.align {
+			left, right := strings.HasPrefix(a, ":"), strings.HasSuffix(a, ":")
+			dashes := widths[i]
+			if left {
+				dashes--
+			}
+			if right {
+				dashes--
+			}
+
+			b.WriteString(" ")
+			if left {
+				b.WriteString(":")
+			}
+			b.WriteString(strings.Repeat("-", dashes))
+			if right {
+				b.WriteString(":")
+			}
+			b.WriteString(" |")
+		}
+		b.WriteString("\n")
+		for _, row := range t.rows {
+			for len(row) < len(t.header) {
+				row = append(row, "")
+			}
+			writeRow(row)
+		}
+
+		out := b.String()
+		if !bytes.HasSuffix(src, []byte("\n")) {
+			out = strings.TrimSuffix(out, "\n")
+		}
+		return []byte(out)
+	})
+}
+
+// padCell pads a cell to width following the alignment of its column's
+// separator cell.
+func padCell(cell string, width int, align string) string {
+	pad := max(0, width-VisibleWidth(cell))
+	switch left, right := strings.HasPrefix(align, ":"), strings.HasSuffix(align, ":"); {
+	case left && right:
+		return strings.Repeat(" ", pad/2) + cell + strings.Repeat(" ", pad-pad/2)
+	case right:
+		return strings.Repeat(" ", pad) + cell
+	}
+	return cell + strings.Repeat(" ", pad)
+}
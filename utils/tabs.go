@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// DefaultTabWidth is the tab width ExpandTabs uses when given zero.
+const DefaultTabWidth = 4
+
+// listItemPattern matches the first line of a list item.
+var listItemPattern = regexp.MustCompile(`^ {0,3}([-*+]|\d+[.)])([ \t]|\r?\n?$)`)
+
+// ExpandTabs replaces the tabs in content with spaces up to the next
+// multiple of tabWidth, or DefaultTabWidth if it isn't positive. Tabs in the
+// frontmatter and in fenced and indented code blocks are kept, as they may
+// be significant there; indented lines inside list items are prose.
+func ExpandTabs(content []byte, tabWidth int) []byte {
+	if tabWidth <= 0 {
+		tabWidth = DefaultTabWidth
+	}
+	body := RemoveFrontmatter(content)
+	out := append([]byte{}, content[:len(content)-len(body)]...)
+
+	blank, inIndented, inList := true, false, false
+	walkLines(body, func(line []byte, _ int, inCode bool) {
+		isBlank := len(bytes.TrimSpace(line)) == 0
+		indented := indentWidth(line, tabWidth) >= 4
+		switch {
+		case inCode:
+			inIndented, inList = false, false
+		case isBlank:
+		case indented && !inList && (blank || inIndented):
+			inIndented = true
+		default:
+			if !indented {
+				inList = listItemPattern.Match(line)
+			}
+			inIndented = false
+			line = expandLineTabs(line, tabWidth)
+		}
+		blank = isBlank
+		out = append(out, line...)
+	})
+	return out
+}
+
+// indentWidth returns the width of the leading whitespace of line.
+func indentWidth(line []byte, tabWidth int) int {
+	width := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			width++
+		case '\t':
+			width += tabWidth - width%tabWidth
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// expandLineTabs replaces the tabs in line with spaces.
+func expandLineTabs(line []byte, tabWidth int) []byte {
+	if bytes.IndexByte(line, '\t') < 0 {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range string(line) {
+		if r == '\t' {
+			n := tabWidth - col%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return []byte(b.String())
+}
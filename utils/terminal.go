@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/term"
+)
+
+// defaultTerminalWidth is used when stdout isn't a terminal.
+const defaultTerminalWidth = 80
+
+// TerminalWidth returns the width of the terminal attached to stdout, or 80
+// when stdout isn't a terminal or its size can't be determined.
+func TerminalWidth() int {
+	fd := int(os.Stdout.Fd()) //nolint:gosec
+	if !term.IsTerminal(fd) {
+		return defaultTerminalWidth
+	}
+	w, _, err := term.GetSize(fd)
+	if err != nil || w <= 0 {
+		return defaultTerminalWidth
+	}
+	return w
+}
+
+// NewRenderer returns a glamour renderer for the given style that wraps at
+// width, or at the terminal's width when width is 0. A negative width
+// disables wrapping. Further options are applied after the defaults.
+func NewRenderer(style string, width int, isCode bool, options ...glamour.TermRendererOption) (*glamour.TermRenderer, error) {
+	switch {
+	case width == 0:
+		width = TerminalWidth()
+	case width < 0:
+		width = 0 // glamour doesn't wrap at width 0
+	}
+
+	r, err := glamour.NewTermRenderer(append([]glamour.TermRendererOption{
+		glamour.WithColorProfile(lipgloss.ColorProfile()),
+		GlamourStyle(style, isCode),
+		glamour.WithWordWrap(width),
+	}, options...)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create renderer: %w", err)
+	}
+	return r, nil
+}
@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	plainImagePattern     = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	plainLinkPattern      = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	plainRefLinkPattern   = regexp.MustCompile(`\[([^\]]+)\]\[[^\]]*\]`)
+	plainLinkDefPattern   = regexp.MustCompile(`(?m)^ {0,3}\[[^\]]+\]:[ \t]*\S.*$`)
+	plainHTMLPattern      = regexp.MustCompile(`<[^>\n]+>`)
+	plainCodeSpanPattern  = regexp.MustCompile("`+([^`]*)`+")
+	plainEmphasisPattern  = regexp.MustCompile(`\*\*|__|~~|\*|\b_|_\b`)
+	plainHeadingPattern   = regexp.MustCompile(`(?m)^ {0,3}#{1,6}[ \t]+|[ \t]+#+[ \t]*$`)
+	plainQuotePattern     = regexp.MustCompile(`(?m)^[ \t]*(>[ \t]?)+`)
+	plainListPattern      = regexp.MustCompile(`(?m)^[ \t]*([-*+]|\d+[.)])[ \t]+(\[[ xX]\][ \t]+)?`)
+	plainRulePattern      = regexp.MustCompile(`(?m)^[ \t]*([-*_=][ \t]*){3,}$`)
+	plainTableSepPattern  = regexp.MustCompile(`(?m)^[ \t]*\|?[ \t]*:?-+:?[ \t]*(\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	plainTablePipePattern = regexp.MustCompile(`[ \t]*\|[ \t]*`)
+	plainBlankPattern     = regexp.MustCompile(`\n{3,}`)
+)
+
+// ToPlainText strips markdown syntax from content, keeping only the prose.
+// Code blocks are dropped, links and images are reduced to their text.
+func ToPlainText(content []byte) []byte {
+	var prose []byte
+	last := 0
+	for _, f := range findFences(content) {
+		prose = append(prose, content[last:f.start]...)
+		last = f.end
+	}
+	prose = append(prose, content[last:]...)
+
+	prose = plainLinkDefPattern.ReplaceAll(prose, nil)
+	prose = plainImagePattern.ReplaceAll(prose, []byte("$1"))
+	prose = plainLinkPattern.ReplaceAll(prose, []byte("$1"))
+	prose = plainRefLinkPattern.ReplaceAll(prose, []byte("$1"))
+	prose = plainHTMLPattern.ReplaceAll(prose, nil)
+	prose = plainCodeSpanPattern.ReplaceAll(prose, []byte("$1"))
+	prose = plainRulePattern.ReplaceAll(prose, nil)
+	prose = plainTableSepPattern.ReplaceAll(prose, nil)
+	prose = plainHeadingPattern.ReplaceAll(prose, nil)
+	prose = plainQuotePattern.ReplaceAll(prose, nil)
+	prose = plainListPattern.ReplaceAll(prose, nil)
+	prose = plainEmphasisPattern.ReplaceAll(prose, nil)
+	prose = plainTablePipePattern.ReplaceAll(prose, []byte(" "))
+	prose = plainBlankPattern.ReplaceAll(prose, []byte("\n\n"))
+
+	return []byte(strings.TrimSpace(string(prose)))
+}
+
+// Excerpt returns the first length characters of the plain text of content,
+// cut back to a word boundary and suffixed with an ellipsis if truncated.
+func Excerpt(content []byte, length int) string {
+	return truncateWords(strings.Join(strings.Fields(string(ToPlainText(content))), " "), length)
+}
+
+// truncateWords shortens s to at most length runes without splitting a word.
+func truncateWords(s string, length int) string {
+	r := []rune(s)
+	if length <= 0 || len(r) <= length {
+		return s
+	}
+
+	cut := string(r[:length])
+	if r[length] != ' ' {
+		if i := strings.LastIndex(cut, " "); i > 0 {
+			cut = cut[:i]
+		}
+	}
+	return strings.TrimRight(cut, " .,;:") + "…"
+}
+
+// wordsPerMinute is the reading speed used to estimate reading time.
+const wordsPerMinute = 200
+
+// WordCount returns the number of words in the prose of content, ignoring
+// code blocks and markdown syntax.
+func WordCount(content []byte) int {
+	return len(strings.Fields(string(ToPlainText(content))))
+}
+
+// ReadingTime estimates the minutes needed to read content, rounded up and
+// never less than one.
+func ReadingTime(content []byte) int {
+	return readingMinutes(WordCount(content))
+}
+
+// readingMinutes returns the reading time of words words.
+func readingMinutes(words int) int {
+	return max(1, (words+wordsPerMinute-1)/wordsPerMinute)
+}
+
+// VisibleCharCount returns how many characters content shows once rendered,
+// leaving out the frontmatter, code blocks and markdown syntax. Links count
+// as their text and images as their alt text, runs of whitespace count as
+// one character, and wide characters such as CJK count as two, matching
+// their width on a terminal.
+func VisibleCharCount(content []byte) int {
+	return VisibleWidth(strings.Join(strings.Fields(string(ToPlainText(RemoveFrontmatter(content)))), " "))
+}
+
+// Slugify turns s into a URL anchor the way GitHub does for headings: lower
+// case, punctuation dropped and spaces replaced by hyphens.
+func Slugify(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), r == '-', r == '_':
+			b.WriteRune(r)
+		case r == ' ':
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+// DefaultBreadcrumbSeparator separates the parts of a Breadcrumb.
+const DefaultBreadcrumbSeparator = " > "
+
+// Breadcrumb describes where path sits below root, e.g. "Docs > Getting
+// Started > Install" for docs/getting-started/install.md with docs as the
+// root. The root directory comes first and every part is title-cased, with
+// dashes and underscores read as spaces. An empty separator means
+// DefaultBreadcrumbSeparator. Paths outside of root only name the file.
+func Breadcrumb(path, root, separator string) string {
+	if separator == "" {
+		separator = DefaultBreadcrumbSeparator
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return titleCase(name)
+	}
+
+	parts := []string{titleCase(filepath.Base(root))}
+	if rel != "." {
+		for _, dir := range strings.Split(rel, string(filepath.Separator)) {
+			parts = append(parts, titleCase(dir))
+		}
+	}
+	return strings.Join(append(parts, titleCase(name)), separator)
+}
+
+// titleCase turns a file name such as "getting-started" into "Getting
+// Started".
+func titleCase(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '-' || r == '_' || unicode.IsSpace(r)
+	})
+	for i, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		words[i] = string(unicode.ToUpper(r)) + w[size:]
+	}
+	return strings.Join(words, " ")
+}
+
+// HumanizeDuration describes how long ago something happened, e.g. "just
+// now", "3 hours ago" or "yesterday". Negative durations are described as
+// being in the future.
+func HumanizeDuration(d time.Duration) string {
+	suffix := "%s ago"
+	if d < 0 {
+		d, suffix = -d, "in %s"
+	}
+
+	const (
+		day   = 24 * time.Hour
+		week  = 7 * day
+		month = 30 * day
+		year  = 365 * day
+	)
+
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf(suffix, countUnit(int(d/time.Minute), "minute"))
+	case d < day:
+		return fmt.Sprintf(suffix, countUnit(int(d/time.Hour), "hour"))
+	case d < 2*day:
+		if suffix == "in %s" {
+			return "tomorrow"
+		}
+		return "yesterday"
+	case d < week:
+		return fmt.Sprintf(suffix, countUnit(int(d/day), "day"))
+	case d < month:
+		return fmt.Sprintf(suffix, countUnit(int(d/week), "week"))
+	case d < year:
+		return fmt.Sprintf(suffix, countUnit(int(d/month), "month"))
+	default:
+		return fmt.Sprintf(suffix, countUnit(int(d/year), "year"))
+	}
+}
+
+// countUnit formats n followed by unit, pluralized in English.
+func countUnit(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// markdownSpecialChars are the characters EscapeMarkdown backslash-escapes.
+const markdownSpecialChars = "\\`*_{}[]<>()#+-!|~"
+
+// EscapeMarkdown backslash-escapes the characters in s that could otherwise
+// start markdown formatting, so it renders literally.
+func EscapeMarkdown(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(markdownSpecialChars, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// truncateWordPattern matches a word, i.e. a run of non-space characters
+	// with at least one letter or digit, so markdown syntax doesn't count.
+	truncateWordPattern = regexp.MustCompile(`[^\s]*[\p{L}\p{N}][^\s]*`)
+	// openLinkPattern matches a link or image cut off before its closing
+	// parenthesis or bracket.
+	openLinkPattern = regexp.MustCompile(`!?\[(?:[^\]]*|[^\]]*\]\([^)]*)$`)
+)
+
+// Truncate shortens content to at most maxWords words, cutting at a word
+// boundary. The frontmatter is dropped. A link cut in half is removed,
+// emphasis and code spans left open are closed, an ellipsis is appended and
+// a code fence left open is closed after it. Content that fits is returned
+// without its frontmatter but otherwise unchanged.
+func Truncate(content []byte, maxWords int) []byte {
+	content = RemoveFrontmatter(content)
+
+	cut, words := -1, 0
+	walkLines(content, func(line []byte, offset int, _ bool) {
+		if cut >= 0 || fenceOpenPattern.Match(line) {
+			return
+		}
+		for _, loc := range truncateWordPattern.FindAllIndex(line, -1) {
+			if words++; words == maxWords+1 {
+				cut = offset + loc[0]
+				return
+			}
+		}
+	})
+	if cut < 0 {
+		return content
+	}
+
+	out := bytes.TrimRightFunc(content[:cut], unicode.IsSpace)
+	// drop a last line that only holds markup, such as the next list bullet
+	// or an opening fence
+	if nl := bytes.LastIndexByte(out, '\n'); nl >= 0 && (!truncateWordPattern.Match(out[nl:]) || fenceOpenPattern.Match(out[nl+1:])) {
+		out = bytes.TrimRightFunc(out[:nl], unicode.IsSpace)
+	}
+	fences := findFences(out)
+	if len(fences) > 0 && !fences[len(fences)-1].closed {
+		f := fences[len(fences)-1]
+		return append(out, "\n"+string(f.marker)+"\n\n…"...)
+	}
+
+	// only the paragraph that was cut can have open inline markup
+	start := bytes.LastIndex(out, []byte("\n\n")) + 1
+	para := out[start:]
+	if loc := openLinkPattern.FindIndex(para); loc != nil {
+		para = bytes.TrimRightFunc(para[:loc[0]], unicode.IsSpace)
+	}
+	para = bytes.TrimRight(para, ".,;:")
+
+	closers := openDelimiters(string(para))
+	var b strings.Builder
+	b.Write(out[:start])
+	b.Write(para)
+	b.WriteString("…")
+	for i := len(closers) - 1; i >= 0; i-- {
+		b.WriteString(closers[i])
+	}
+	return []byte(b.String())
+}
+
+// openDelimiters returns the emphasis, strikethrough and code span
+// delimiters left open at the end of s, innermost last.
+func openDelimiters(s string) []string {
+	var open []string
+	toggle := func(d string) {
+		if n := len(open); n > 0 && open[n-1] == d {
+			open = open[:n-1]
+			return
+		}
+		open = append(open, d)
+	}
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '\\':
+			i += 2
+		case c == '`':
+			n := len(s[i:]) - len(strings.TrimLeft(s[i:], "`"))
+			ticks := s[i : i+n]
+			end := strings.Index(s[i+n:], ticks)
+			if end < 0 {
+				return append(open, ticks)
+			}
+			i += n + end + n
+		case c == '*' || c == '_' || c == '~':
+			n := len(s[i:]) - len(strings.TrimLeft(s[i:], string(c)))
+			before, _ := utf8.DecodeLastRuneInString(s[:i])
+			after, _ := utf8.DecodeRuneInString(s[i+n:])
+			wordChar := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+			space := func(r rune) bool { return r == utf8.RuneError || unicode.IsSpace(r) }
+			switch {
+			case c == '~' && n < 2:
+			case space(before) && space(after):
+				// list bullets and lone asterisks aren't emphasis
+			case c == '_' && wordChar(before) && wordChar(after):
+				// neither are intraword underscores, as in snake_case
+			default:
+				toggle(s[i : i+n])
+			}
+			i += n
+		default:
+			i++
+		}
+	}
+	return open
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TypographyOptions configures NormalizeTypographyWithOptions.
+type TypographyOptions struct {
+	// NoQuotes leaves straight quotes alone, converting only dashes and
+	// ellipses.
+	NoQuotes bool
+}
+
+var (
+	// typographyProtectedPattern matches the spans NormalizeTypography must
+	// leave alone: code spans, placeholders, link targets, HTML tags, URLs
+	// and link definitions.
+	typographyProtectedPattern = regexp.MustCompile("(?m)`+[^`]*`+" +
+		`|\{\{.*?\}\}|\]\([^)]*\)|<[^>\n]+>|https?://[^\s<>]+|^ {0,3}\[[^\]]+\]:.*$`)
+
+	typographyReplacer = strings.NewReplacer(" --- ", " — ", " -- ", " – ")
+	wordEmDashPattern  = regexp.MustCompile(`(\w)---(\w)`)
+	numberRangePattern = regexp.MustCompile(`(\d)--(\d)`)
+	ellipsisPattern    = regexp.MustCompile(`\.{3,}`)
+)
+
+// NormalizeTypography converts straight quotes to curly ones, "---" to em
+// dashes, "--" to en dashes and "..." to ellipses in prose. Frontmatter, code,
+// URLs, HTML and placeholders are left alone, and dashes are only converted
+// between words or numbers so command line flags such as --help survive.
+func NormalizeTypography(content []byte) []byte {
+	return NormalizeTypographyWithOptions(content, TypographyOptions{})
+}
+
+// NormalizeTypographyWithOptions is like NormalizeTypography but lets the
+// caller turn off quote conversion.
+func NormalizeTypographyWithOptions(content []byte, opts TypographyOptions) []byte {
+	body := RemoveFrontmatter(content)
+	frontmatter := content[:len(content)-len(body)]
+
+	body = mapOutsideFences(body, func(b []byte) []byte {
+		var out strings.Builder
+		last := 0
+		prev := ' '
+		for _, loc := range typographyProtectedPattern.FindAllIndex(b, -1) {
+			out.WriteString(typographyText(string(b[last:loc[0]]), prev, opts))
+			out.Write(b[loc[0]:loc[1]])
+			prev, _ = utf8.DecodeLastRune(b[:loc[1]])
+			last = loc[1]
+		}
+		out.WriteString(typographyText(string(b[last:]), prev, opts))
+		return []byte(out.String())
+	})
+
+	return append(append([]byte{}, frontmatter...), body...)
+}
+
+// typographyText applies the typographic replacements to a span of prose
+// that follows the rune prev.
+func typographyText(s string, prev rune, opts TypographyOptions) string {
+	if s == "" {
+		return s
+	}
+
+	s = typographyReplacer.Replace(s)
+	s = wordEmDashPattern.ReplaceAllString(s, "$1—$2")
+	s = numberRangePattern.ReplaceAllString(s, "$1–$2")
+	s = ellipsisPattern.ReplaceAllStringFunc(s, func(dots string) string {
+		if len(dots) == 3 {
+			return "…"
+		}
+		return dots
+	})
+	if opts.NoQuotes {
+		return s
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		before := prev
+		if i > 0 {
+			before = runes[i-1]
+		}
+		opening := unicode.IsSpace(before) || strings.ContainsRune("([{—–", before)
+
+		switch r {
+		case '"':
+			if opening {
+				runes[i] = '“'
+			} else {
+				runes[i] = '”'
+			}
+		case '\'':
+			if opening && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+				runes[i] = '‘'
+			} else {
+				runes[i] = '’'
+			}
+		}
+	}
+	return string(runes)
+}
@@ -3,14 +3,16 @@ package utils
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"maps"
 	"os"
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/glamour/ansi"
@@ -54,25 +56,51 @@ func RemoveFrontmatter(content []byte) []byte {
 	return content
 }
 
+// RemoveFrontmatterTidy is like RemoveFrontmatter but always cuts right
+// after the closing fence and drops at most one blank line following it, so
+// the body starts the same way whether or not the author left a blank line
+// there, and any further blank lines are kept.
+func RemoveFrontmatterTidy(content []byte) []byte {
+	if detectFrontmatter(content)[0] != 0 {
+		return content
+	}
+	closing := yamlPattern.FindAllIndex(content, 2)[1][0]
+	body := content[closing+bytes.IndexByte(content[closing:], '\n')+1:]
+	if line, rest, ok := bytes.Cut(body, []byte("\n")); ok && len(bytes.TrimSpace(line)) == 0 {
+		return rest
+	}
+	return body
+}
+
 // extractFrontmatterVars reads YAML frontmatter (if present) and returns a flattened map plus the bounds.
 func extractFrontmatterVars(content []byte) (map[string]string, []int) {
-	fmBounds := detectFrontmatter(content)
+	raw, fmBounds, _ := parseFrontmatter(content)
 	vars := make(map[string]string)
+	flattenYAML("", raw, vars)
+	return vars, fmBounds
+}
+
+// parseFrontmatter reads YAML frontmatter (if present) and returns it as
+// parsed, without flattening, plus the bounds and any YAML error.
+func parseFrontmatter(content []byte) (map[string]interface{}, []int, error) {
+	fmBounds := detectFrontmatter(content)
+	var raw map[string]interface{}
 
 	if fmBounds[0] == 0 && fmBounds[1] > fmBounds[0] {
 		fmBytes := content[fmBounds[0]:fmBounds[1]]
-		// strip the leading and trailing '---' lines
-		trim := bytes.TrimPrefix(fmBytes, []byte("---"))
-		trim = bytes.TrimSuffix(trim, []byte("---"))
+		// strip the leading and trailing '---' lines, which may be indented
+		trim := fmBytes[bytes.IndexByte(fmBytes, '\n')+1:]
+		if end := bytes.LastIndex(trim, []byte("---")); end >= 0 {
+			trim = trim[:end]
+		}
 		trim = bytes.TrimSpace(trim)
 
-		var raw map[string]interface{}
-		if err := yaml.Unmarshal(trim, &raw); err == nil {
-			flattenYAML("", raw, vars)
+		if err := yaml.Unmarshal(trim, &raw); err != nil {
+			return nil, fmBounds, err
 		}
 	}
 
-	return vars, fmBounds
+	return raw, fmBounds, nil
 }
 
 func flattenYAML(prefix string, in interface{}, out map[string]string) {
@@ -101,46 +129,75 @@ func flattenYAML(prefix string, in interface{}, out map[string]string) {
 
 // PreprocessDynamicText replaces some contents of the markdown file with dynamically generated contents.
 func PreprocessDynamicText(content []byte, currentDir string, processedPaths map[string]bool) []byte {
+	return PreprocessDynamicTextWithOptions(content, currentDir, processedPaths, PreprocessOptions{})
+}
+
+// PreprocessDynamicTextWithOptions is like PreprocessDynamicText but lets the
+// caller tune how the dynamic contents are generated.
+func PreprocessDynamicTextWithOptions(content []byte, currentDir string, processedPaths map[string]bool, opts PreprocessOptions) []byte {
+	if opts.RestrictToBase && opts.BaseDir == "" {
+		opts.BaseDir = currentDir
+	}
+	if opts.BreadcrumbRoot == "" {
+		opts.BreadcrumbRoot = currentDir
+	}
+	if opts.remoteCache == nil {
+		opts.remoteCache = make(map[string]remoteInclude)
+	}
 
-	vars, _ := extractFrontmatterVars(content)
+	raw, _, _ := parseFrontmatter(content)
+	vars := make(map[string]string)
+	flattenYAML("", raw, vars)
 	content = RemoveFrontmatter(content)
+
+	// {% raw %} blocks pass through verbatim, and LaTeX braces are kept
+	// away from the placeholder parser.
+	outermost := opts.rawBlocks == nil
+	if outermost {
+		opts.rawBlocks = new([][]byte)
+	}
+	content = protectRaw(content, opts.rawBlocks)
+	content, restoreMath := ProtectMath(content)
+
+	// {{! comments }} are for template authors and never rendered.
+	content = commentPattern.ReplaceAll(content, nil)
+
+	// Inline frontmatter takes precedence over a sidecar file.
+	if opts.Path != "" && opts.allowsSidecar() {
+		if sidecar, err := LoadSidecarFrontmatter(opts.Path); err == nil {
+			for k, v := range sidecar {
+				if _, ok := vars[k]; !ok {
+					vars[k] = v
+				}
+			}
+		}
+	}
+
+	// default_lang tags the code blocks that don't name a language.
+	content = ApplyDefaultCodeLang(content, vars["default_lang"])
 	//fmt.Println("Processed Paths:", processedPaths) // Debug print out the processed paths for recursion validation
+	frontmatterVars := maps.Clone(vars)
+
+	// Built-ins are only computed when the document refers to them.
+	metaKeys, metaSeparator := opts.MetadataKeys, opts.MetadataSeparator
+	if metaKeys == nil {
+		metaKeys = DefaultMetadataKeys
+	}
+	if metaSeparator == "" {
+		metaSeparator = DefaultMetadataSeparator
+	}
+	used := findUsedKeys(content, vars)
+	if opts.allBuiltins {
+		used.add(builtinKeys...)
+	}
 
 	// Built-ins (non-variable defined vars)
-	now := time.Now()
-	hour := now.Hour()
-
-	vars["datetime_rfc3339"] = now.Format(time.RFC3339)
-	vars["datetime_rfc1123"] = now.Format(time.RFC1123)
-	vars["datetime"] = now.Format("2006-01-02 15:04")
-	vars["datetime_iso"] = now.Format("2006-01-02 15:04:05")
-	vars["date_short"] = now.Format("2006-01-02")
-	vars["date_long"] = now.Format("Jan 02, 2006")
-	vars["date_full"] = now.Format("Monday, 02 Jan 2006")
-	vars["custom_date"] = now.Format(vars["custom_date_fmt"]) // user custom_date_fmt var to format the date string
-	vars["date"] = vars["date_short"]
-
-	vars["time_12h"] = now.Format("03:04 PM")
-	vars["time_24h"] = now.Format("15:04")
-	vars["time_long"] = now.Format("15:04:05")
-	vars["time"] = vars["time_24h"]
-	vars["tz_short"] = now.Format("MST")
-	vars["tz_offset"] = now.Format("-7:00")
-	vars["tz"] = vars["tz_short"]
-
-	if hour >= 5 && hour < 12 {
-		vars["timeofday"] = "morning"
-		vars["timeofday_emoji"] = "☕"
-	} else if hour >= 12 && hour < 17 {
-		vars["timeofday"] = "afternoon"
-		vars["timeofday_emoji"] = "☀️"
-	} else {
-		vars["timeofday"] = "evening"
-		vars["timeofday_emoji"] = "🌙"
+	if used.any(timeBuiltins...) {
+		addTimeVars(vars)
 	}
 
 	cwd, err := os.Getwd()
-	if err == nil {
+	if err == nil && used.any("pwd", "cwd", "pwd_short", "cwd_short") {
 		vars["pwd"] = cwd
 		vars["cwd"] = cwd
 		cwd_short := filepath.Base(cwd)
@@ -151,17 +208,94 @@ func PreprocessDynamicText(content []byte, currentDir string, processedPaths map
 		vars["cwd_short"] = cwd_short
 	}
 
-	curuser, err := user.Current()
+	if used["user"] {
+		curuser, err := user.Current()
 
-	if err == nil {
-		vars["user"] = curuser.Username
+		if err == nil {
+			vars["user"] = curuser.Username
+		}
 	}
 
-	for k, v := range vars {
-		re := regexp.MustCompile(`\{\{\s*` + regexp.QuoteMeta(k) + `\s*\}\}`)
-		content = re.ReplaceAll(content, []byte(v))
+	if opts.Path != "" && used.any("breadcrumb", "last_modified", "last_modified_relative") {
+		addFileVars(vars, opts)
 	}
 
+	content = expandLoops(content, raw)
+	content = expandConditionals(content, vars, opts.truthy())
+
+	// Excerpts are built from the body with its placeholders, math and raw
+	// blocks left out. An explicit excerpt in the frontmatter always wins.
+	var plainText []byte
+	plain := func() []byte {
+		if plainText == nil {
+			plainText = anyPlaceholderPattern.ReplaceAll(content, nil)
+			plainText = rawPlaceholderPattern.ReplaceAll(mathPlaceholderPattern.ReplaceAll(plainText, nil), nil)
+		}
+		return plainText
+	}
+	explicitExcerpt, hasExcerpt := vars["excerpt"]
+	if !hasExcerpt && used["excerpt"] {
+		vars["excerpt"] = Excerpt(plain(), defaultExcerptLength)
+	}
+	content = excerptPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		n, err := strconv.Atoi(string(excerptPattern.FindSubmatch(match)[1]))
+		if err != nil {
+			return match
+		}
+		if hasExcerpt {
+			return []byte(truncateWords(explicitExcerpt, n))
+		}
+		return []byte(Excerpt(plain(), n))
+	})
+
+	if used["word_count"] {
+		vars["word_count"] = strconv.Itoa(WordCount(plain()))
+	}
+	if used["reading_time"] {
+		vars["reading_time"] = strconv.Itoa(ReadingTime(plain()))
+	}
+
+	if used.any("heading_count", "section_count") {
+		headings := findHeadings(content)
+		sections := 0
+		for _, h := range headings {
+			if h.level == 1 {
+				sections++
+			}
+		}
+		vars["heading_count"] = strconv.Itoa(len(headings))
+		vars["section_count"] = strconv.Itoa(sections)
+	}
+
+	if used["todo_count"] {
+		markers := opts.TodoMarkers
+		if markers == nil {
+			markers = DefaultTodoMarkers
+		}
+		vars["todo_count"] = strconv.Itoa(len(FindMarkers(content, markers)))
+	}
+
+	if used["meta_line"] {
+		// like MetadataLine, the byline is built from the frontmatter and the
+		// computed counts only, so it never shows today's date
+		metaVars := maps.Clone(frontmatterVars)
+		metaVars["word_count"] = strconv.Itoa(WordCount(plain()))
+		metaVars["reading_time"] = strconv.Itoa(ReadingTime(plain()))
+		vars["meta_line"] = metadataLine(metaVars, metaKeys, metaSeparator)
+	}
+
+	if used["tags_footer"] {
+		vars["tags_footer"] = tagsFooter(frontmatterVars["tags"], opts.TagsFooterLabel)
+	}
+
+	if used["cover_image"] {
+		if _, src, ok := FirstImage(content); ok {
+			vars["cover_image"] = src
+		}
+	}
+
+	content = substitutePasses(content, frontmatterResolver(vars, raw, opts.Rand), opts.MaxPasses)
+
 	// Find all cases of {{inject[filepath]}}
 	// Open the file if filepath exists
 	// Render the contents by preprocessing (recursive)
@@ -182,39 +316,180 @@ func PreprocessDynamicText(content []byte, currentDir string, processedPaths map
 			absPath = filepath.Join(currentDir, relPath)
 		}
 
-		if processedPaths[absPath] {
-			//fmt.Println("Recursive file injection detected: %s. Skipping.", relPath)
-			//fmt.Println("Processed Paths:", processedPaths) // Debug print out the processed paths for recursion validation
-			return []byte(fmt.Sprintf("`{{inject_recursion_error: %s -> %s}}`", relPath, processedPaths))
+		return injectFile("inject", relPath, absPath, processedPaths, opts)
+	})
+
+	// Third pass: handle {{ include: glob }}, splicing every matching file
+	content = includeRegex.ReplaceAllFunc(content, func(match []byte) []byte {
+		pattern := string(includeRegex.FindSubmatch(match)[1])
+		absPattern := pattern
+		if !filepath.IsAbs(pattern) {
+			absPattern = filepath.Join(currentDir, pattern)
 		}
 
-		// Read the file content
-		injectedContent, err := os.ReadFile(absPath)
+		// filepath.Glob returns the matches sorted
+		matches, err := filepath.Glob(absPattern)
 		if err != nil {
-			return []byte(fmt.Sprintf("{{inject_error: %s}}", err)) // Indicate error
+			return []byte(fmt.Sprintf("{{include_error: %s}}", err))
+		}
+		if len(matches) == 0 {
+			return []byte(fmt.Sprintf("{{include_error: no files match %s}}", pattern))
 		}
 
-		// Add the new path to the map for the recursive call
-		newProcessedPaths := make(map[string]bool)
-		maps.Copy(newProcessedPaths, processedPaths)
-		newProcessedPaths[absPath] = true
+		// a wildcard matching the document or a file including it isn't a
+		// cycle the user asked for, so those files are left out; a literal
+		// path still goes through the recursion guard
+		isGlob := strings.ContainsAny(pattern, "*?[")
+		parts := make([][]byte, 0, len(matches))
+		for _, absPath := range matches {
+			if isGlob && includesFile(absPath, processedPaths, opts) {
+				continue
+			}
+			relPath, err := filepath.Rel(currentDir, absPath)
+			if err != nil {
+				relPath = absPath
+			}
+			parts = append(parts, bytes.TrimRight(injectFile("include", relPath, absPath, processedPaths, opts), "\n"))
+		}
+		return bytes.Join(parts, []byte("\n\n"))
+	})
 
-		// Recursively preprocess the injected content
-		// We pass the directory of the injected file for correct relative path resolution
-		injectedDir := filepath.Dir(absPath)
-		return PreprocessDynamicText(injectedContent, injectedDir, newProcessedPaths)
+	// Fourth pass: handle {{ include_url: url }}
+	content = includeURLRegex.ReplaceAllFunc(content, func(match []byte) []byte {
+		return includeURL(string(includeURLRegex.FindSubmatch(match)[1]), opts)
 	})
 
+	// Fifth pass: handle {{ include_code: path }}, fencing the file as code
+	content = includeCodeRegex.ReplaceAllFunc(content, func(match []byte) []byte {
+		return includeCode(string(includeCodeRegex.FindSubmatch(match)[1]), currentDir, opts)
+	})
+
+	content = restoreMath(content)
+	if outermost {
+		content = restoreRaw(content, *opts.rawBlocks)
+	}
 	return content
 }
 
-var yamlPattern = regexp.MustCompile(`(?m)^---\r?\n(\s*\r?\n)?`)
+// includesFile reports whether absPath is the document being preprocessed
+// or one of the files that include it.
+func includesFile(absPath string, processedPaths map[string]bool, opts PreprocessOptions) bool {
+	canonical := canonicalPath(absPath)
+	return processedPaths[absPath] || opts.Path != "" && canonicalPath(opts.Path) == canonical ||
+		slices.Contains(opts.includeStack, canonical)
+}
+
+// includeCode reads the file at path for an include_code directive and wraps
+// it in a fenced code block tagged with the language of its extension.
+func includeCode(path, currentDir string, opts PreprocessOptions) []byte {
+	absPath := path
+	if !filepath.IsAbs(path) {
+		absPath = filepath.Join(currentDir, path)
+	}
+	if !opts.allowsPath(absPath) {
+		return []byte(fmt.Sprintf("{{include_code_error: %s is outside of %s}}", path, opts.BaseDir))
+	}
+
+	b, err := readFileUTF8(absPath)
+	if err != nil {
+		return []byte(fmt.Sprintf("{{include_code_error: unable to read %s}}", path))
+	}
+	code := string(b)
+	if code != "" && !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+	return []byte(WrapCodeBlock(code, LanguageForExtension(filepath.Ext(path))))
+}
+
+// injectFile reads and preprocesses the file at absPath for an inject or
+// include directive, guarding against recursive injection. Errors are
+// reported under the name of the directive.
+func injectFile(directive, relPath, absPath string, processedPaths map[string]bool, opts PreprocessOptions) []byte {
+	if !opts.allowsPath(absPath) {
+		return []byte(fmt.Sprintf("{{%s_error: %s is outside of %s}}", directive, relPath, opts.BaseDir))
+	}
+
+	canonical := canonicalPath(absPath)
+	chain := append(slices.Clone(opts.includeStack), canonical)
+	if processedPaths[absPath] || slices.Contains(opts.includeStack, canonical) {
+		opts.recordIncludeError(&IncludeCycleError{Chain: chain})
+		//fmt.Println("Recursive file injection detected: %s. Skipping.", relPath)
+		//fmt.Println("Processed Paths:", processedPaths) // Debug print out the processed paths for recursion validation
+		return []byte(fmt.Sprintf("`{{%s_recursion_error: %s -> %v}}`", directive, relPath, processedPaths))
+	}
+
+	if len(opts.includeStack) >= opts.maxIncludeDepth() {
+		opts.recordIncludeError(fmt.Errorf("include depth of %d exceeded: %s", opts.maxIncludeDepth(), strings.Join(chain, " -> ")))
+		return []byte(fmt.Sprintf("{{%s_error: include depth of %d exceeded}}", directive, opts.maxIncludeDepth()))
+	}
+
+	// Read the file content
+	injectedContent, err := readFileUTF8(absPath)
+	if err != nil {
+		return []byte(fmt.Sprintf("{{%s_error: %s}}", directive, err)) // Indicate error
+	}
+
+	// Add the new path to the map for the recursive call
+	newProcessedPaths := make(map[string]bool)
+	maps.Copy(newProcessedPaths, processedPaths)
+	newProcessedPaths[absPath] = true
+
+	// Recursively preprocess the injected content
+	// We pass the directory of the injected file for correct relative path resolution
+	injectedDir := filepath.Dir(absPath)
+	injectedOpts := opts
+	injectedOpts.Path = absPath
+	injectedOpts.includeStack = chain
+	return PreprocessDynamicTextWithOptions(injectedContent, injectedDir, newProcessedPaths, injectedOpts)
+}
+
+const defaultExcerptLength = 160
+
+var (
+	anyPlaceholderPattern = regexp.MustCompile(`\{\{.*?\}\}`)
+	excerptPattern        = regexp.MustCompile(`\{\{\s*excerpt_(\d+)\s*\}\}`)
+	includeRegex          = regexp.MustCompile(`\{\{\s*include:\s*(.*?)\s*\}\}`)
+	includeURLRegex       = regexp.MustCompile(`\{\{\s*include_url:\s*(.*?)\s*\}\}`)
+	includeCodeRegex      = regexp.MustCompile(`\{\{\s*include_code:\s*(.*?)\s*\}\}`)
+)
 
+// yamlPattern matches a --- fence line. Like CommonMark, up to three spaces
+// of indentation are allowed; more would make it code.
+var yamlPattern = regexp.MustCompile(`(?m)^ {0,3}---\r?\n(\s*\r?\n)?`)
+
+// yamlKeyLinePattern matches a line that starts a YAML mapping entry.
+var yamlKeyLinePattern = regexp.MustCompile(`^\s*(?:[\w"'-][^:\n]*|"[^"\n]*"|'[^'\n]*'):(?:\s|$)`)
+
+// detectFrontmatter returns the bounds of the frontmatter block at the very
+// start of c, fences included, or [-1, -1]. A --- line further down can't
+// open frontmatter, so a Setext heading's underline is never taken for one.
+// The block must be empty or a YAML mapping; otherwise the fences are
+// thematic breaks around prose. Invalid YAML still counts when every line
+// looks like YAML, so its error can be reported.
 func detectFrontmatter(c []byte) []int {
-	if matches := yamlPattern.FindAllIndex(c, 2); len(matches) > 1 {
-		return []int{matches[0][0], matches[1][1]}
+	matches := yamlPattern.FindAllIndex(c, 2)
+	if len(matches) < 2 || matches[0][0] != 0 {
+		return []int{-1, -1}
+	}
+
+	block := c[matches[0][1]:matches[1][0]]
+	var mapping map[string]interface{}
+	if len(bytes.TrimSpace(block)) > 0 && yaml.Unmarshal(block, &mapping) != nil && !looksLikeYAML(block) {
+		return []int{-1, -1}
+	}
+	return []int{matches[0][0], matches[1][1]}
+}
+
+// looksLikeYAML reports whether every non-blank line of block starts a
+// mapping entry or is an indented continuation of one.
+func looksLikeYAML(block []byte) bool {
+	for _, line := range bytes.Split(block, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if len(bytes.TrimSpace(line)) > 0 && line[0] != ' ' && line[0] != '\t' && !yamlKeyLinePattern.Match(line) {
+			return false
+		}
 	}
-	return []int{-1, -1}
+	return true
 }
 
 // ExpandPath expands tilde and all environment variables from the given path.
@@ -258,42 +533,98 @@ func IsMarkdownFile(filename string) bool {
 // GlamourStyle returns a glamour.TermRendererOption based on the given style.
 func GlamourStyle(style string, isCode bool) glamour.TermRendererOption {
 	if !isCode {
+		if styleConfig, ok := registeredStyle(style); ok {
+			return glamour.WithStyles(styleConfig)
+		}
 		if style == styles.AutoStyle {
 			return glamour.WithAutoStyle()
 		}
+		if style == AdaptiveStyle {
+			styleConfig, _ := builtinStyleConfig(style)
+			return glamour.WithStyles(styleConfig)
+		}
 		return glamour.WithStylePath(style)
 	}
 
 	// If we are rendering a pure code block, we need to modify the style to
 	// remove the indentation.
 
+	styleConfig, ok := builtinStyleConfig(style)
+	if !ok {
+		return glamour.WithStylesFromJSONFile(style)
+	}
+
+	stripCodeBlockMargin(&styleConfig)
+
+	return glamour.WithStyles(styleConfig)
+}
+
+// GlamourStyleWithMargin is like GlamourStyle but sets the code block margin
+// instead of removing it, for prose as well as pure code, so
+// GlamourStyle(style, true) renders like GlamourStyleWithMargin(style, 0).
+// Style names and paths are resolved like GlamourStyle resolves them.
+func GlamourStyleWithMargin(style string, margin uint) glamour.TermRendererOption {
+	styleConfig, err := styleConfigFor(style)
+	if err != nil {
+		return func(*glamour.TermRenderer) error { return err }
+	}
+
+	styleConfig.CodeBlock.Margin = &margin
+	return glamour.WithStyles(styleConfig)
+}
+
+// styleConfigFor returns the style config for a registered or built-in
+// style name, one of glamour's default styles, or the path of a JSON style
+// file, which may start with a tilde.
+func styleConfigFor(style string) (ansi.StyleConfig, error) {
+	if styleConfig, ok := builtinStyleConfig(style); ok {
+		return styleConfig, nil
+	}
+	if styleConfig, ok := styles.DefaultStyles[style]; ok {
+		return *styleConfig, nil
+	}
+
 	var styleConfig ansi.StyleConfig
+	b, err := os.ReadFile(ExpandPath(style))
+	if err != nil {
+		return styleConfig, fmt.Errorf("unable to read style: %w", err)
+	}
+	if err := json.Unmarshal(b, &styleConfig); err != nil {
+		return styleConfig, fmt.Errorf("unable to decode style: %w", err)
+	}
+	return styleConfig, nil
+}
 
+// builtinStyleConfig returns the style config for a registered or built-in
+// style name, resolving the auto style against the terminal background.
+func builtinStyleConfig(style string) (ansi.StyleConfig, bool) {
+	if styleConfig, ok := registeredStyle(style); ok {
+		return styleConfig, true
+	}
 	switch style {
 	case styles.AutoStyle:
 		if lipgloss.HasDarkBackground() {
-			styleConfig = styles.DarkStyleConfig
-		} else {
-			styleConfig = styles.LightStyleConfig
+			return styles.DarkStyleConfig, true
+		}
+		return styles.LightStyleConfig, true
+	case AdaptiveStyle:
+		if luminance, ok := BackgroundLuminance(); ok {
+			return StyleForBackground(luminance), true
 		}
+		return builtinStyleConfig(styles.AutoStyle)
 	case styles.DarkStyle:
-		styleConfig = styles.DarkStyleConfig
+		return styles.DarkStyleConfig, true
 	case styles.LightStyle:
-		styleConfig = styles.LightStyleConfig
+		return styles.LightStyleConfig, true
 	case styles.PinkStyle:
-		styleConfig = styles.PinkStyleConfig
+		return styles.PinkStyleConfig, true
 	case styles.NoTTYStyle:
-		styleConfig = styles.NoTTYStyleConfig
+		return styles.NoTTYStyleConfig, true
 	case styles.DraculaStyle:
-		styleConfig = styles.DraculaStyleConfig
+		return styles.DraculaStyleConfig, true
 	case styles.TokyoNightStyle:
-		styleConfig = styles.DraculaStyleConfig
+		return styles.DraculaStyleConfig, true
 	default:
-		return glamour.WithStylesFromJSONFile(style)
+		return ansi.StyleConfig{}, false
 	}
-
-	var margin uint
-	styleConfig.CodeBlock.Margin = &margin
-
-	return glamour.WithStyles(styleConfig)
 }